@@ -1,29 +1,17 @@
 package bootstrap
 
 import (
-	"encoding/json"
-	"errors"
 	"github.com/aoaostar/mooc/pkg/config"
-	"io"
-	"os"
 )
 
+// InitConfig 加载配置, 环境变量和命令行参数覆盖配置文件中的值
 func InitConfig() error {
 
-	file, err := os.Open("./config.json")
-	if err != nil {
-		return errors.New("读取配置文件失败: " + err.Error())
-	}
-	readAll, err := io.ReadAll(file)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(readAll, &config.Conf)
-
+	conf, err := config.Load(config.LoadOptions)
 	if err != nil {
 		return err
 	}
+	config.Conf = conf
 	return nil
 
 }
@@ -0,0 +1,152 @@
+package bootstrap
+
+import (
+	"errors"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// 配置管理器
+type ConfigManager struct {
+	configPath string
+	config     config.Config
+	mu         sync.RWMutex
+}
+
+// 创建配置管理器
+func NewConfigManager(configPath string) *ConfigManager {
+	manager := &ConfigManager{
+		configPath: configPath,
+	}
+	
+	// 加载配置
+	manager.LoadConfig()
+	
+	return manager
+}
+
+// OpenConfigManager 只读取已有的配置文件, 不会创建默认配置, 供命令行使用
+// create 为 true 时文件不存在则使用默认配置, 调用 SaveConfig 时才写入文件
+func OpenConfigManager(configPath string, create bool) (*ConfigManager, error) {
+	manager := &ConfigManager{
+		configPath: configPath,
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if !create {
+			return nil, errors.New("配置文件不存在: " + configPath)
+		}
+		manager.config = config.Default()
+		return manager, nil
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager, manager.readConfigInternal()
+}
+
+// 加载配置
+func (cm *ConfigManager) LoadConfig() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	// 检查配置文件是否存在
+	if _, err := os.Stat(cm.configPath); os.IsNotExist(err) {
+		// 创建默认配置
+		cm.config = config.Default()
+		
+		// 保存默认配置
+		return cm.saveConfigInternal()
+	}
+	
+	return cm.readConfigInternal()
+}
+
+// 内部读取配置方法
+func (cm *ConfigManager) readConfigInternal() error {
+	// 读取配置文件, 主文件损坏时回退到备份
+	data, _, err := util.ReadFileWithBackup(cm.configPath, util.DefaultBackups, func(data []byte) error {
+		return config.Valid(cm.configPath, data)
+	})
+	if err != nil {
+		return err
+	}
+	
+	// 按扩展名解析JSON、TOML或YAML
+	cm.config = config.Default()
+	err = config.Unmarshal(cm.configPath, data, &cm.config)
+	if err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// 配置文件路径
+func (cm *ConfigManager) Path() string {
+	return cm.configPath
+}
+
+// 获取配置
+func (cm *ConfigManager) GetConfig() config.Config {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	
+	return cm.config
+}
+
+// 保存配置
+func (cm *ConfigManager) SaveConfig(conf config.Config) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	cm.config = conf
+	return cm.saveConfigInternal()
+}
+
+// 内部保存配置方法
+func (cm *ConfigManager) saveConfigInternal() error {
+	// 按扩展名序列化
+	data, err := config.Marshal(cm.configPath, cm.config)
+	if err != nil {
+		return err
+	}
+	
+	// 原子写入文件, 并保留备份
+	return util.WriteFileAtomic(cm.configPath, data, 0600, util.DefaultBackups)
+}
+
+// 导入配置
+func (cm *ConfigManager) ImportConfig(path string) error {
+	// 读取文件
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	
+	// 按扩展名解析JSON、TOML或YAML
+	conf := config.Default()
+	err = config.Unmarshal(path, data, &conf)
+	if err != nil {
+		return err
+	}
+	
+	// 保存配置
+	return cm.SaveConfig(conf)
+}
+
+// 导出配置
+func (cm *ConfigManager) ExportConfig(path string) error {
+	// 获取当前配置
+	conf := cm.GetConfig()
+	
+	// 按扩展名序列化
+	data, err := config.Marshal(path, conf)
+	if err != nil {
+		return err
+	}
+	
+	// 原子写入文件
+	return util.WriteFileAtomic(path, data, 0600, 0)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
+	"sync"
 )
 
 // 自定义日志Hook
@@ -21,7 +22,16 @@ func (h *GuiLogHook) Fire(entry *logrus.Entry) error {
 	return nil
 }
 
+// LogToConsole 日志是否同时输出到控制台
+var LogToConsole bool
+
+var logOnce sync.Once
+
 func InitLog() {
+	logOnce.Do(initLog)
+}
+
+func initLog() {
 	// 设置日志格式
 	logrus.SetFormatter(&logrus.TextFormatter{
 		DisableColors:   false,
@@ -34,7 +44,10 @@ func InitLog() {
 	
 	// 创建日志文件
 	file, err := os.OpenFile("./logs/aoaostar.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
+	if err == nil && LogToConsole {
+		// 命令行模式同时输出到文件和控制台
+		logrus.SetOutput(io.MultiWriter(os.Stdout, file))
+	} else if err == nil {
 		// 同时输出到文件，但不再输出到控制台
 		logrus.SetOutput(file)
 	} else {
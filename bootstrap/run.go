@@ -1,19 +1,33 @@
 package bootstrap
 
 import (
+	"errors"
 	"fmt"
+	"github.com/aoaostar/mooc/pkg/breaker"
 	"github.com/aoaostar/mooc/pkg/config"
 	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/todo"
 	"github.com/aoaostar/mooc/pkg/util"
 	"github.com/aoaostar/mooc/pkg/yinghua"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 // 任务状态观察者接口
 type TaskStatusObserver interface {
-	OnTaskStatusChanged(task task.Task, status string, progress float64)
+	OnTaskStatusChanged(task task.Task, status task.Status, progress float64, reason string)
 	OnTaskCompleted(task task.Task)
 	OnTaskError(task task.Task, err error)
+	OnTaskEstimate(task task.Task, remaining time.Duration, eta time.Time)
+	// OnTaskTodo 课程结束学习时还有需要手动完成的非视频节点
+	OnTaskTodo(task task.Task, items []todo.Item)
+}
+
+// 服务器熔断观察者接口
+type BreakerObserver interface {
+	// OnBreakerChanged 学校服务器熔断、探测或恢复
+	OnBreakerChanged(change breaker.Change)
 }
 
 // 日志观察者接口
@@ -24,21 +38,27 @@ type LogObserver interface {
 // 观察者列表
 var taskObservers []TaskStatusObserver
 var logObservers []LogObserver
+var breakerObservers []BreakerObserver
 
 // RegisterTaskObserver 注册任务观察者
 func RegisterTaskObserver(observer TaskStatusObserver) {
 	taskObservers = append(taskObservers, observer)
 }
 
+// RegisterBreakerObserver 注册服务器熔断观察者
+func RegisterBreakerObserver(observer BreakerObserver) {
+	breakerObservers = append(breakerObservers, observer)
+}
+
 // RegisterLogObserver 注册日志观察者
 func RegisterLogObserver(observer LogObserver) {
 	logObservers = append(logObservers, observer)
 }
 
-// NotifyTaskStatus 通知任务状态变更
-func NotifyTaskStatus(task task.Task, status string, progress float64) {
+// NotifyTaskStatus 通知任务状态变更, reason 为变更原因
+func NotifyTaskStatus(task task.Task, status task.Status, progress float64, reason string) {
 	for _, observer := range taskObservers {
-		observer.OnTaskStatusChanged(task, status, progress)
+		observer.OnTaskStatusChanged(task, status, progress, reason)
 	}
 }
 
@@ -56,41 +76,126 @@ func NotifyTaskError(task task.Task, err error) {
 	}
 }
 
+// NotifyTaskEstimate 通知任务剩余时间和预计完成时间变更
+func NotifyTaskEstimate(task task.Task, remaining time.Duration, eta time.Time) {
+	for _, observer := range taskObservers {
+		observer.OnTaskEstimate(task, remaining, eta)
+	}
+}
+
+// NotifyTaskTodo 通知课程中需要手动完成的节点
+func NotifyTaskTodo(task task.Task, items []todo.Item) {
+	for _, observer := range taskObservers {
+		observer.OnTaskTodo(task, items)
+	}
+}
+
+// NotifyBreakerChanged 通知服务器熔断状态变更
+func NotifyBreakerChanged(change breaker.Change) {
+	for _, observer := range breakerObservers {
+		observer.OnBreakerChanged(change)
+	}
+}
+
+func init() {
+	breaker.Subscribe(NotifyBreakerChanged)
+	// 将任务事件转发给观察者
+	task.Subscribe(func(event task.Event) {
+		NotifyTaskStatus(event.Task, event.Status, event.Progress, event.Message)
+		// 完成和失败只在状态变化时通知一次
+		if event.From != event.Status {
+			switch event.Status {
+			case task.StatusDone:
+				NotifyTaskCompleted(event.Task)
+			case task.StatusFailed:
+				err := event.Err
+				if err == nil {
+					err = errors.New(event.Message)
+				}
+				NotifyTaskError(event.Task, err)
+			}
+			// 课程学完或跳过时提醒还需要手动完成的节点
+			if (event.Status == task.StatusDone || event.Status == task.StatusSkipped) && len(event.Todos) > 0 {
+				NotifyTaskTodo(event.Task, event.Todos)
+			}
+		}
+		NotifyTaskEstimate(event.Task, time.Duration(event.Remaining)*time.Second, event.ETA)
+	})
+}
+
 // Run 启动核心引擎
 func Run() {
+	// GUI中不启动Web服务
+	err := Start(false)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// Start 加载配置, 登录全部账号并执行任务, web 为 true 时同时启动Web服务
+func Start(web bool) error {
 	InitLog()
-	util.Copyright()
+	util.Copyright(config.VERSION)
 	err := InitConfig()
 	if err != nil {
-		logrus.Fatal(err)
+		return err
 	}
 	
-	// 移除Web服务启动
-	// go InitWeb()
+	if web {
+		go InitWeb()
+	}
 	
 	for _, user := range config.Conf.Users {
-		send(user)
+		err = send(user)
+		if err != nil {
+			return err
+		}
 	}
 	task.Start()
+	return nil
 }
 
-func send(user config.User) {
+func send(user config.User) error {
 	instance := yinghua.New(user)
 	err := instance.Login()
 	if err != nil {
-		logrus.Fatal(err)
+		return fmt.Errorf("[%s] 登录失败: %s", user.Username, err.Error())
 	}
 	instance.Output(fmt.Sprintf("登录成功"))
 	err = instance.GetCourses()
 	if err != nil {
-		logrus.Fatal(err)
+		return fmt.Errorf("[%s] 获取课程失败: %s", user.Username, err.Error())
 	}
 	instance.Output(fmt.Sprintf("获取全部在学课程成功, 共计 %d 门\n", len(instance.Courses)))
 	for _, course := range instance.Courses {
-		task.Tasks = append(task.Tasks, task.Task{
-			User:   user,
-			Course: course,
-			Status: false,
+		if reason := task.ExcludeReason(user, course); reason != "" {
+			instance.Output(fmt.Sprintf("课程[%s][%d] 不满足选课规则, 已排除: %s", course.Name, course.ID, reason))
+			continue
+		}
+		task.AddTask(task.Task{
+			User:     user,
+			Course:   course,
+			Estimate: estimate(instance, course),
 		})
 	}
+	return nil
+}
+
+// estimate 按章节中未完成视频的时长估算课程剩余学习时间, 获取失败时返回0
+func estimate(instance *yinghua.YingHua, course types.CoursesList) int {
+	if task.SkipReason(course) != "" {
+		return 0
+	}
+	chapters, err := instance.GetChapters(course)
+	if err != nil {
+		instance.Output(fmt.Sprintf("[%s] 获取章节失败, 无法估算剩余时间: %s", course.Name, err.Error()))
+		return 0
+	}
+	seconds := 0
+	for _, chapter := range chapters {
+		for _, node := range chapter.NodeList {
+			seconds += node.RemainingSeconds()
+		}
+	}
+	return seconds
 }
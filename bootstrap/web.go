@@ -1,17 +1,42 @@
 package bootstrap
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/aoaostar/mooc/pkg/captcha"
 	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/history"
+	"github.com/aoaostar/mooc/pkg/ratelimit"
+	"github.com/aoaostar/mooc/pkg/report"
+	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/todo"
 	"github.com/aoaostar/mooc/pkg/util"
 	"github.com/sirupsen/logrus"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func InitWeb() {
-	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+	mux := http.NewServeMux()
+	routes(mux)
+	logrus.Infof("web端启动成功, 请访问 %s 查看服务状态", config.Conf.Global.Server)
+	err := http.ListenAndServe(config.Conf.Global.Server, mux)
+	if err != nil {
+		logrus.Fatal(err.Error())
+	}
+
+}
+
+// routes 注册web端的页面和接口
+func routes(mux *http.ServeMux) {
+	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
 
 		file, err := os.Open("./view/index.html")
 		if err != nil {
@@ -29,7 +54,7 @@ func InitWeb() {
 		}
 
 	})
-	http.HandleFunc("/ajax", func(writer http.ResponseWriter, request *http.Request) {
+	mux.HandleFunc("/ajax", func(writer http.ResponseWriter, request *http.Request) {
 
 		text, err := util.ReadText("./logs/aoaostar.log", 0, 100)
 		if err != nil {
@@ -42,10 +67,249 @@ func InitWeb() {
 		}
 
 	})
-	logrus.Infof("web端启动成功, 请访问 %s 查看服务状态", config.Conf.Global.Server)
-	err := http.ListenAndServe(config.Conf.Global.Server, nil)
+	mux.HandleFunc("/api/status", func(writer http.ResponseWriter, request *http.Request) {
+		writeJson(writer, Status())
+	})
+	// 调整任务优先级: POST /api/priority?key=任务标识&priority=数值, 需要授权
+	mux.HandleFunc("/api/priority", protected(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		priority, err := strconv.Atoi(request.FormValue("priority"))
+		if err != nil {
+			http.Error(writer, "priority 应为整数", http.StatusBadRequest)
+			return
+		}
+		if !task.SetPriority(request.FormValue("key"), priority) {
+			http.Error(writer, "任务不存在", http.StatusNotFound)
+			return
+		}
+		writeJson(writer, Status())
+	}))
+	// 运行结果, 运行结束前返回当前的统计
+	mux.HandleFunc("/api/summary", func(writer http.ResponseWriter, request *http.Request) {
+		writeJson(writer, Summary())
+	})
+	// 课程进度报告: GET /api/report?format=csv|markdown|html&user=账号, 不传 user 时为全部账号, 需要授权
+	mux.HandleFunc("/api/report", protected(func(writer http.ResponseWriter, request *http.Request) {
+		format := request.FormValue("format")
+		if format == "" {
+			format = report.FormatHTML
+		}
+		format, err := report.ParseFormat(format)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		user := request.FormValue("user")
+		result, ok := Report(user)
+		if !ok && user != "" {
+			http.Error(writer, "账号不存在", http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Type", report.ContentType(format))
+		if format != report.FormatHTML {
+			writer.Header().Set("Content-Disposition", "attachment; filename=report"+report.Extension(format))
+		}
+		err = report.Write(writer, format, result)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}))
+	// 需要手动完成的非视频节点: GET /api/todo?user=账号, 不传 user 时包括全部账号, 需要授权
+	mux.HandleFunc("/api/todo", protected(func(writer http.ResponseWriter, request *http.Request) {
+		user := request.FormValue("user")
+		users, ok := Todos(user)
+		if !ok && user != "" {
+			http.Error(writer, "账号不存在", http.StatusNotFound)
+			return
+		}
+		writeJson(writer, users)
+	}))
+	// 课程进度历史: GET /api/history?user=账号&course=课程ID&days=天数, 默认最近7天
+	mux.HandleFunc("/api/history", protected(func(writer http.ResponseWriter, request *http.Request) {
+		courseID, err := strconv.Atoi(request.FormValue("course"))
+		if err != nil {
+			http.Error(writer, "course 应为课程ID", http.StatusBadRequest)
+			return
+		}
+		days := 7
+		if value := request.FormValue("days"); value != "" {
+			days, err = strconv.Atoi(value)
+			if err != nil || days <= 0 {
+				http.Error(writer, "days 应为正整数", http.StatusBadRequest)
+				return
+			}
+		}
+		snapshots, err := history.Course(UserKey(request.FormValue("user")), courseID, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(writer, snapshots)
+	}))
+	// 进度变化: GET /api/history/changes?since=起始时间 或 hours=小时数, 默认最近24小时
+	mux.HandleFunc("/api/history/changes", protected(func(writer http.ResponseWriter, request *http.Request) {
+		since, err := ParseSince(request.FormValue("since"), request.FormValue("hours"))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		changes, err := history.Changes(since)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(writer, changes)
+	}))
+	// 等待手动输入的验证码: GET 列表, POST ?id=序号&code=验证码 提交
+	mux.HandleFunc("/api/captcha", protected(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodGet {
+			writeJson(writer, captcha.Pending())
+			return
+		}
+		if request.Method != http.MethodPost {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !captcha.Submit(request.FormValue("id"), request.FormValue("code")) {
+			http.Error(writer, "验证码不存在、已超时或输入为空", http.StatusNotFound)
+			return
+		}
+		writeJson(writer, captcha.Pending())
+	}))
+	// 验证码图片: GET /api/captcha/image?id=序号
+	mux.HandleFunc("/api/captcha/image", protected(func(writer http.ResponseWriter, request *http.Request) {
+		item, ok := captcha.Lookup(request.FormValue("id"))
+		if !ok {
+			http.Error(writer, "验证码不存在或已超时", http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Type", http.DetectContentType(item.Image))
+		_, err := writer.Write(item.Image)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}))
+}
+
+// StatusResponse 运行状态
+type StatusResponse struct {
+	Version  string                   `json:"version"`
+	Tasks    []task.State             `json:"tasks"`
+	Estimate task.Estimate            `json:"estimate"`
+	Users    map[string]task.Estimate `json:"users"`
+	// RateLimits 每个平台域名的限速统计
+	RateLimits []ratelimit.Stat `json:"rate_limits"`
+	// Breakers 每个平台域名的熔断器状态
+	Breakers map[string]breaker.Status `json:"breakers"`
+	// Captchas 等待手动输入的验证码
+	Captchas []captcha.Request `json:"captchas"`
+}
+
+// Status 当前运行状态
+func Status() StatusResponse {
+	total, users := task.Estimates()
+	return StatusResponse{
+		Version:    config.VERSION,
+		Tasks:      task.States(),
+		Estimate:   total,
+		Users:      users,
+		RateLimits: ratelimit.Stats(),
+		Breakers:   breaker.States(),
+		Captchas:   captcha.Pending(),
+	}
+}
+
+// Summary 运行结束后返回最终结果, 运行中返回当前的统计
+func Summary() task.Summary {
+	if summary, ok := task.LastSummary(); ok {
+		return summary
+	}
+	return task.Summarize()
+}
+
+// UserKey 把用户名转换为账号标识, 找不到时原样返回
+func UserKey(name string) string {
+	for _, user := range config.Conf.Users {
+		if user.Username == name {
+			return user.Key()
+		}
+	}
+	return name
+}
+
+// ParseSince 解析查询的起始时间, 都为空时为24小时之前
+func ParseSince(since string, hours string) (time.Time, error) {
+	if since != "" {
+		if parse, err := time.Parse(time.RFC3339, since); err == nil {
+			return parse, nil
+		}
+		for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+			if parse, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+				return parse, nil
+			}
+		}
+		return time.Time{}, errors.New("since 格式应为 2006-01-02、2006-01-02 15:04 或 2006-01-02T15:04:05+08:00")
+	}
+	value := 24
+	if hours != "" {
+		var err error
+		value, err = strconv.Atoi(hours)
+		if err != nil || value <= 0 {
+			return time.Time{}, errors.New("hours 应为正整数")
+		}
+	}
+	return time.Now().Add(-time.Duration(value) * time.Hour), nil
+}
+
+// Report 用运行中的任务已经获取的课程信息生成进度报告, 不会重新登录, user 为空时包括全部账号
+// 没有对应的任务时返回 false
+func Report(user string) (report.Report, bool) {
+	return task.Report(user)
+}
+
+// Todos 运行中的任务已经获取的待办事项, 不会重新登录, user 为空时包括全部账号
+// 没有对应的任务时返回 false
+func Todos(user string) ([]todo.User, bool) {
+	return task.UserTodos(user)
+}
+
+// authorized 检查请求是否可以访问需要授权的接口
+// 设置了 global.token 时需要携带令牌, 否则只允许本机访问
+func authorized(request *http.Request) bool {
+	token := config.Conf.Global.Token
+	if token != "" {
+		given := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if given == "" {
+			given = request.URL.Query().Get("token")
+		}
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
 	if err != nil {
-		logrus.Fatal(err.Error())
+		return false
 	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
 
+// protected 需要授权的接口
+func protected(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if !authorized(request) {
+			http.Error(writer, "未授权: 请携带 global.token, 未设置令牌时只允许本机访问", http.StatusUnauthorized)
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+func writeJson(writer http.ResponseWriter, data interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err := json.NewEncoder(writer).Encode(data)
+	if err != nil {
+		logrus.Error(err)
+	}
 }
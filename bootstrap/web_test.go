@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+)
+
+func TestProtected(t *testing.T) {
+	handler := protected(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name   string
+		token  string
+		remote string
+		header string
+		query  string
+		want   int
+	}{
+		{name: "未设置令牌时本机访问", remote: "127.0.0.1:50000", want: http.StatusOK},
+		{name: "未设置令牌时IPv6本机访问", remote: "[::1]:50000", want: http.StatusOK},
+		{name: "未设置令牌时其他设备访问", remote: "192.168.1.2:50000", want: http.StatusUnauthorized},
+		{name: "设置令牌后本机不带令牌", token: "secret", remote: "127.0.0.1:50000", want: http.StatusUnauthorized},
+		{name: "请求头携带令牌", token: "secret", remote: "192.168.1.2:50000", header: "Bearer secret", want: http.StatusOK},
+		{name: "参数携带令牌", token: "secret", remote: "192.168.1.2:50000", query: "secret", want: http.StatusOK},
+		{name: "令牌错误", token: "secret", remote: "192.168.1.2:50000", header: "Bearer wrong", want: http.StatusUnauthorized},
+	}
+	old := config.Conf.Global.Token
+	defer func() { config.Conf.Global.Token = old }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.Conf.Global.Token = test.token
+			request := httptest.NewRequest(http.MethodGet, "/api/captcha?token="+test.query, nil)
+			request.RemoteAddr = test.remote
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, request)
+			if recorder.Code != test.want {
+				t.Fatalf("状态码为 %d, 应为 %d", recorder.Code, test.want)
+			}
+		})
+	}
+}
+
+func TestRoutesRequireAuthorization(t *testing.T) {
+	mux := http.NewServeMux()
+	routes(mux)
+	old := config.Conf.Global.Token
+	defer func() { config.Conf.Global.Token = old }()
+	config.Conf.Global.Token = ""
+	for _, path := range []string{"/api/history?course=1", "/api/history/changes", "/api/report", "/api/todo", "/api/priority",
+		"/api/captcha", "/api/captcha/image"} {
+		t.Run(path, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, path, nil)
+			request.RemoteAddr = "192.168.1.2:50000"
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("其他设备不带令牌访问 %s 的状态码为 %d, 应为 %d", path, recorder.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/captcha"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// captchaDir 命令行模式下验证码图片的保存目录
+var captchaDir = filepath.Join(config.DataDir, "captcha")
+
+// watchCaptcha 需要手动输入验证码时保存图片并从标准输入读取
+// 输入格式为 "序号 验证码", 只有一个验证码等待输入时可以省略序号
+func watchCaptcha() {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		// 标准输入不是终端时只能在web端输入
+		return
+	}
+	captcha.Subscribe(func(event captcha.Event) {
+		path := filepath.Join(captchaDir, event.Request.ID+".png")
+		if event.Closed {
+			_ = os.Remove(path)
+			return
+		}
+		if err := os.MkdirAll(captchaDir, 0755); err != nil {
+			logrus.Errorf("保存验证码图片失败: %s", err.Error())
+			return
+		}
+		if err := os.WriteFile(path, event.Request.Image, 0644); err != nil {
+			logrus.Errorf("保存验证码图片失败: %s", err.Error())
+			return
+		}
+		logrus.Warnf("[%s] 需要输入验证码, 图片: %s, 请输入 \"%s 验证码\" 后回车, %s 前有效",
+			event.Request.User, path, event.Request.ID, event.Request.Deadline.Format("15:04:05"))
+	})
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			pending := captcha.Pending()
+			id, code := "", fields[0]
+			if len(fields) >= 2 {
+				id, code = fields[0], fields[1]
+			} else if len(pending) == 1 {
+				id = pending[0].ID
+			}
+			if id == "" {
+				fmt.Println("有多个验证码等待输入, 请输入 \"序号 验证码\"")
+				continue
+			}
+			if !captcha.Submit(id, code) {
+				fmt.Printf("验证码 %s 不存在或已经超时\n", id)
+			}
+		}
+	}()
+}
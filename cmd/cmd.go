@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strings"
+)
+
+// Execute 解析命令行参数并执行子命令, 没有子命令时执行 fallback
+func Execute(args []string, fallback func() error) error {
+	flags := flag.NewFlagSet("mooc", flag.ContinueOnError)
+	flags.Usage = func() { usage(flags) }
+	path := flags.String("config", "", "配置文件路径")
+	server := flags.String("server", "", "web端监听地址, 覆盖 global.server")
+	limit := flags.Int("limit", 0, "协程数, 覆盖 global.limit")
+	debug := flags.Bool("debug", false, "输出调试日志, 包括限速等待")
+	var sets setFlags
+	flags.Var(&sets, "set", "覆盖任意配置项, 如 --set global.limit=5, 可重复")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	overrides := map[string]string{}
+	for _, item := range sets {
+		overrides[item[0]] = item[1]
+	}
+	if *server != "" {
+		overrides["global.server"] = *server
+	}
+	if *limit != 0 {
+		overrides["global.limit"] = fmt.Sprint(*limit)
+	}
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	config.LoadOptions = config.Options{
+		Path:  *path,
+		Flags: overrides,
+	}
+
+	rest := flags.Args()
+	if len(rest) == 0 {
+		return fallback()
+	}
+	switch rest[0] {
+	case "run":
+		return Run(rest[1:])
+	case "plan":
+		return planCommand(rest[1:])
+	case "users":
+		return usersCommand(rest[1:])
+	case "courses":
+		return coursesCommand(rest[1:])
+	case "todo":
+		return todoCommand(rest[1:])
+	case "history":
+		return historyCommand(rest[1:])
+	case "report":
+		return reportCommand(rest[1:])
+	case "status":
+		return statusCommand(rest[1:])
+	case "config":
+		return configCommand(rest[1:])
+	case "help":
+		usage(flags)
+		return nil
+	}
+	return fmt.Errorf("未知命令: %s", rest[0])
+}
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, `用法: mooc [参数] [命令]
+
+命令:
+  run [--web]                 登录全部账号并开始学习, --web 同时启动web端
+  run --dry-run               等同于 plan
+  plan [账号] [--nodes] [--json]
+                              列出待学习的课程、节点和剩余视频时长, 不会提交学习记录
+  users list                  列出全部账号
+  users add                   添加账号, 用 users add -h 查看参数
+  users remove <账号>          删除账号, 账号可以是序号、用户名或 用户名@域名
+  users test <账号>            测试账号能否登录
+  courses <账号>               列出账号的全部课程
+  report [账号] [--format 格式] [--output 文件]
+                              生成课程进度报告, 格式为 csv、markdown、html
+  todo [账号] [--json]         列出需要手动完成的作业、考试等非视频节点
+  history <账号> <课程ID> [--days 天数]
+                              查看课程的进度历史, 默认最近7天
+  history changes [--hours 小时数] [--since 起始时间]
+                              列出有变化的课程, 默认最近24小时
+  status                      查看正在运行的任务状态
+  config show                 显示生效的配置及每一项的来源
+  config validate             检查配置文件
+  config convert <源> <目标>   在JSON、TOML、YAML之间转换配置文件, 格式由扩展名决定
+
+子命令的参数可以写在账号等位置参数的前面或后面, 如 todo alice --json 与 todo --json alice 相同
+
+参数:`)
+	flags.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n环境变量: %s\n", strings.Join(envHelp(), ", "))
+}
+
+func envHelp() []string {
+	names := []string{config.EnvPrefix + "CONFIG"}
+	for _, key := range config.Keys() {
+		names = append(names, config.EnvPrefix+strings.ToUpper(strings.ReplaceAll(key, ".", "_")))
+	}
+	return names
+}
+
+// setFlags 解析 --set key=value
+type setFlags [][2]string
+
+func (s *setFlags) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *setFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("格式应为 key=value")
+	}
+	*s = append(*s, [2]string{strings.TrimSpace(parts[0]), parts[1]})
+	return nil
+}
+
+// parseFlags 解析子命令的参数, 参数可以写在位置参数前面或后面, 返回位置参数
+// flag 包遇到第一个位置参数就停止解析, 这里跳过位置参数后继续解析剩下的部分
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// -- 之后全部是位置参数
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// selectUsers 按位置参数选择账号, 没有位置参数时返回全部账号, 最多只能指定一个账号
+func selectUsers(users []config.User, args []string, usage string) ([]config.User, error) {
+	switch len(args) {
+	case 0:
+		return users, nil
+	case 1:
+		index, err := findUser(users, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return users[index : index+1], nil
+	}
+	return nil, fmt.Errorf("多余的参数: %s, 用法: %s", strings.Join(args[1:], " "), usage)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"os"
+	"text/tabwriter"
+)
+
+func configCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少子命令, 可用: show, validate, convert")
+	}
+	switch args[0] {
+	case "show":
+		return configShow()
+	case "validate":
+		return configValidate()
+	case "convert":
+		return configConvert(args[1:])
+	}
+	return fmt.Errorf("未知命令: config %s", args[0])
+}
+
+// configShow 显示生效的配置及其来源
+func configShow() error {
+	err := bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "配置项\t值\t来源")
+	for _, setting := range config.Describe(config.Conf) {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Origin)
+	}
+	return writer.Flush()
+}
+
+// configValidate 检查配置, 有问题时返回错误
+func configValidate() error {
+	err := bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	errs := config.Conf.Validate()
+	if len(errs) == 0 {
+		fmt.Printf("配置文件 %s 检查通过, 共 %d 个账号\n", config.Path, len(config.Conf.Users))
+		return nil
+	}
+	fmt.Printf("配置文件 %s 存在 %d 个问题:\n", config.Path, len(errs))
+	for _, err := range errs {
+		fmt.Printf("  - %s\n", err.Error())
+	}
+	return errors.New("配置检查未通过")
+}
+
+// configConvert 在JSON、TOML、YAML之间转换配置文件, 格式由扩展名决定
+func configConvert(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("用法: config convert <源文件> <目标文件>")
+	}
+	src, dst := args[0], args[1]
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	output, err := config.Convert(src, data, dst)
+	if err != nil {
+		return err
+	}
+	err = util.WriteFileAtomic(dst, output, 0600, util.DefaultBackups)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已转换 %s(%s) => %s(%s)\n", src, config.FormatOf(src), dst, config.FormatOf(dst))
+	return nil
+}
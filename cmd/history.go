@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/history"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// historyCommand 查询课程进度历史
+func historyCommand(args []string) error {
+	if len(args) > 0 && args[0] == "changes" {
+		return historyChanges(args[1:])
+	}
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	days := flags.Int("days", 7, "查询最近几天")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || *days <= 0 {
+		return errors.New("用法: history <账号> <课程ID> [--days 天数]")
+	}
+	courseID, err := strconv.Atoi(positional[1])
+	if err != nil {
+		return fmt.Errorf("课程ID应为整数: %s", positional[1])
+	}
+	err = bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	index, err := findUser(config.Conf.Users, positional[0])
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	snapshots, err := history.Course(config.Conf.Users[index].Key(), courseID, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("最近 %d 天没有该课程的进度记录\n", *days)
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "时间\t进度\t视频\t成绩\t排名\t已完成节点")
+	for _, snapshot := range snapshots {
+		done := 0
+		for _, node := range snapshot.Nodes {
+			if node.VideoState == 2 {
+				done++
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%.0f%%\t%d/%d\t%g\t%d\t%d/%d\n", snapshot.Time.Format("01-02 15:04"), snapshot.Progress*100,
+			snapshot.VideoLearned, snapshot.VideoCount, snapshot.Score, snapshot.Rank, done, len(snapshot.Nodes))
+	}
+	return writer.Flush()
+}
+
+// historyChanges 列出每门课程从指定时间以来的变化
+func historyChanges(args []string) error {
+	flags := flag.NewFlagSet("history changes", flag.ContinueOnError)
+	hours := flags.String("hours", "", "查询最近几小时, 默认24")
+	since := flags.String("since", "", "起始时间, 如 2006-01-02 或 \"2006-01-02 15:04\"")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errors.New("用法: history changes [--hours 小时数] [--since 起始时间]")
+	}
+	from, err := bootstrap.ParseSince(*since, *hours)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	changes, err := history.Changes(from)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s 以来没有变化\n", from.Format("2006-01-02 15:04"))
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "账号\t课程\t进度\t视频\t成绩\t排名\t节点变化")
+	for _, change := range changes {
+		name := change.Course
+		if change.New {
+			name += "(新)"
+		}
+		completed := 0
+		for _, node := range change.Nodes {
+			if node.To == 2 {
+				completed++
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%.0f%% -> %.0f%%\t%d -> %d\t%g -> %g\t%d -> %d\t%d 个, 完成 %d 个\n", change.User, name,
+			change.ProgressFrom*100, change.ProgressTo*100, change.VideoLearnedFrom, change.VideoLearnedTo,
+			change.ScoreFrom, change.ScoreTo, change.RankFrom, change.RankTo, len(change.Nodes), completed)
+	}
+	return writer.Flush()
+}
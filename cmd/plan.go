@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/plan"
+	"os"
+)
+
+// planCommand 列出每个账号待学习的内容, 不会提交学习记录
+func planCommand(args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	verbose := flags.Bool("nodes", false, "列出每个节点")
+	asJson := flags.Bool("json", false, "以JSON格式输出")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	users, err := selectUsers(config.Conf.Users, positional, "plan [账号] [--nodes] [--json]")
+	if err != nil {
+		return err
+	}
+	plans := plan.Build(users)
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	}
+	return plan.Print(os.Stdout, plans, *verbose)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/report"
+	"github.com/aoaostar/mooc/pkg/util"
+	"os"
+)
+
+// reportCommand 生成每个账号的课程进度报告
+func reportCommand(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := flags.String("format", "", "报告格式: csv、markdown、html, 默认根据 --output 的扩展名判断, 都没有时为 markdown")
+	output := flags.String("output", "", "保存到文件, 默认输出到终端")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	kind := report.FormatMarkdown
+	switch {
+	case *format != "":
+		kind, err = report.ParseFormat(*format)
+	case *output != "":
+		kind, err = report.FormatOf(*output)
+	}
+	if err != nil {
+		return err
+	}
+	err = bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	users, err := selectUsers(config.Conf.Users, positional, "report [账号] [--format 格式] [--output 文件]")
+	if err != nil {
+		return err
+	}
+	result := report.Build(users)
+	if *output == "" {
+		return report.Write(os.Stdout, kind, result)
+	}
+	var buffer bytes.Buffer
+	err = report.Write(&buffer, kind, result)
+	if err != nil {
+		return err
+	}
+	err = util.WriteFileAtomic(*output, buffer.Bytes(), 0644, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("报告已保存到 %s\n", *output)
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"strings"
+)
+
+// Run 无界面运行核心引擎
+func Run(args []string) error {
+	// --dry-run 时其余参数交给 plan 处理
+	for index, arg := range args {
+		if arg == "--dry-run" || arg == "-dry-run" {
+			return planCommand(append(args[:index:index], args[index+1:]...))
+		}
+	}
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	web := flags.Bool("web", false, "同时启动web端, 地址为 global.server")
+	flags.Bool("dry-run", false, "只列出学习计划, 不开始学习, 参数同 plan")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	bootstrap.LogToConsole = true
+	err := bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	if errs := config.Conf.Validate(); len(errs) > 0 {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return errors.New("配置检查未通过, 运行 config validate 查看详情: " + strings.Join(messages, "; "))
+	}
+	watchCaptcha()
+	return bootstrap.Start(*web)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/util"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// statusCommand 查询正在运行的引擎, 没有运行时显示断点记录
+func statusCommand(args []string) error {
+	err := bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	status, err := fetchStatus(config.Conf.Global.Server)
+	if err != nil {
+		fmt.Printf("未能连接到运行中的任务 (%s), 显示断点记录\n", err.Error())
+		return printCheckpoints()
+	}
+	fmt.Printf("版本: %s, 任务数: %d\n", status.Version, len(status.Tasks))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "账号\t课程\t状态\t进度\t优先级\t剩余时间\t预计完成\t更新时间\t说明")
+	for _, state := range status.Tasks {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%.0f%%\t%d\t%s\t%s\t%s\t%s\n", state.User, state.Course, state.Status,
+			state.Progress*100, state.Priority, formatRemaining(state.Remaining), formatETA(state.Remaining, state.ETA),
+			state.UpdatedAt.Format("01-02 15:04:05"), describeState(state))
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	var users []string
+	for user := range status.Users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		estimate := status.Users[user]
+		fmt.Printf("[%s] 剩余 %s, 预计 %s 完成\n", user, formatRemaining(estimate.Remaining), formatETA(estimate.Remaining, estimate.ETA))
+	}
+	fmt.Printf("全部剩余 %s, 预计 %s 完成\n", formatRemaining(status.Estimate.Remaining), formatETA(status.Estimate.Remaining, status.Estimate.ETA))
+	return nil
+}
+
+// describeState 任务说明, 不在学习时段内时显示距离下一个时段的倒计时
+func describeState(state task.State) string {
+	if state.NextWindow.IsZero() {
+		return state.Message
+	}
+	countdown := fmt.Sprintf("%s 后进入学习时段", time.Until(state.NextWindow).Round(time.Second))
+	if state.Message == "" {
+		return countdown
+	}
+	return state.Message + ", " + countdown
+}
+
+func formatRemaining(seconds int) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	return util.FormatSeconds(seconds)
+}
+
+func formatETA(seconds int, eta time.Time) string {
+	if seconds <= 0 || eta.IsZero() {
+		return "-"
+	}
+	return eta.Format("01-02 15:04")
+}
+
+func fetchStatus(server string) (bootstrap.StatusResponse, error) {
+	var status bootstrap.StatusResponse
+	if strings.HasPrefix(server, ":") {
+		server = "127.0.0.1" + server
+	}
+	client := http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get("http://" + server + "/api/status")
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("web端返回 %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
+
+func printCheckpoints() error {
+	checkpoints, err := task.LoadCheckpoints()
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		fmt.Println("暂无断点记录")
+		return nil
+	}
+	var list []task.Checkpoint
+	for _, checkpoint := range checkpoints {
+		list = append(list, checkpoint)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].User != list[j].User {
+			return list[i].User < list[j].User
+		}
+		return list[i].CourseID < list[j].CourseID
+	})
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "账号\t课程\t已完成\t已学节点\t更新时间")
+	for _, checkpoint := range list {
+		done := "否"
+		if checkpoint.Done {
+			done = "是"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", checkpoint.User, checkpoint.Course, done,
+			len(checkpoint.Nodes), checkpoint.UpdatedAt.Format("01-02 15:04:05"))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"os"
+)
+
+// todoCommand 列出每个账号需要手动完成的作业、考试等非视频节点
+func todoCommand(args []string) error {
+	flags := flag.NewFlagSet("todo", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "以JSON格式输出")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = bootstrap.InitConfig()
+	if err != nil {
+		return err
+	}
+	users, err := selectUsers(config.Conf.Users, positional, "todo [账号] [--json]")
+	if err != nil {
+		return err
+	}
+	todos := todo.Build(users)
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(todos)
+	}
+	return todo.Print(os.Stdout, todos)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/yinghua"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func usersCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少子命令, 可用: list, add, remove, test")
+	}
+	switch args[0] {
+	case "list":
+		return usersList()
+	case "add":
+		return usersAdd(args[1:])
+	case "remove":
+		return usersRemove(args[1:])
+	case "test":
+		return usersTest(args[1:])
+	}
+	return fmt.Errorf("未知命令: users %s", args[0])
+}
+
+// openConfig 打开配置文件, 修改账号时直接读写文件, 不带入环境变量和命令行覆盖的值
+// create 为 true 时配置文件不存在也可以打开, 保存时创建
+func openConfig(create bool) (*bootstrap.ConfigManager, error) {
+	path, _ := config.ResolvePath(config.LoadOptions.Path)
+	return bootstrap.OpenConfigManager(path, create)
+}
+
+// findUser 按序号、用户名或账号标识查找账号
+func findUser(users []config.User, name string) (int, error) {
+	if index, err := strconv.Atoi(name); err == nil && index >= 0 && index < len(users) {
+		return index, nil
+	}
+	found := -1
+	for index, user := range users {
+		if user.Key() == name {
+			return index, nil
+		}
+		if user.Username == name {
+			if found >= 0 {
+				return -1, fmt.Errorf("存在多个用户名为 %s 的账号, 请使用 用户名@域名", name)
+			}
+			found = index
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("账号不存在: %s", name)
+	}
+	return found, nil
+}
+
+func usersList() error {
+	manager, err := openConfig(false)
+	if err != nil {
+		return err
+	}
+	conf := manager.GetConfig()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "序号\t账号\t姓名\t平台\t备注")
+	for index, user := range conf.Users {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n", index, user.Key(), user.Name, user.Platform, user.Remark)
+	}
+	return writer.Flush()
+}
+
+func usersAdd(args []string) error {
+	flags := flag.NewFlagSet("users add", flag.ContinueOnError)
+	user := config.User{}
+	flags.StringVar(&user.BaseURL, "base-url", "", "学校平台地址, 如 https://mooc.school.com")
+	flags.IntVar(&user.SchoolID, "school-id", 0, "学校ID")
+	flags.StringVar(&user.Username, "username", "", "用户名")
+	flags.StringVar(&user.Password, "password", "", "密码, 不填时从标准输入读取")
+	flags.StringVar(&user.Name, "name", "", "姓名")
+	flags.StringVar(&user.Platform, "platform", "", "平台")
+	flags.StringVar(&user.Remark, "remark", "", "备注")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if user.BaseURL == "" || user.Username == "" {
+		return errors.New("--base-url 和 --username 不能为空")
+	}
+	if user.Password == "" {
+		fmt.Print("密码: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("读取密码失败: " + err.Error())
+		}
+		user.Password = strings.TrimSpace(line)
+	}
+
+	manager, err := openConfig(true)
+	if err != nil {
+		return err
+	}
+	conf := manager.GetConfig()
+	for _, existing := range conf.Users {
+		if existing.Key() == user.Key() {
+			return fmt.Errorf("账号已存在: %s", user.Key())
+		}
+	}
+	conf.Users = append(conf.Users, user)
+	err = manager.SaveConfig(conf)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已添加账号 %s 到 %s\n", user.Key(), manager.Path())
+	return nil
+}
+
+func usersRemove(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: users remove <账号>")
+	}
+	manager, err := openConfig(false)
+	if err != nil {
+		return err
+	}
+	conf := manager.GetConfig()
+	index, err := findUser(conf.Users, args[0])
+	if err != nil {
+		return err
+	}
+	user := conf.Users[index]
+	conf.Users = append(conf.Users[:index:index], conf.Users[index+1:]...)
+	err = manager.SaveConfig(conf)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已删除账号 %s\n", user.Key())
+	return nil
+}
+
+// loginUser 加载配置并登录指定账号
+func loginUser(name string) (*yinghua.YingHua, error) {
+	err := bootstrap.InitConfig()
+	if err != nil {
+		return nil, err
+	}
+	index, err := findUser(config.Conf.Users, name)
+	if err != nil {
+		return nil, err
+	}
+	instance := yinghua.New(config.Conf.Users[index])
+	err = instance.Login()
+	if err != nil {
+		return nil, fmt.Errorf("%s 登录失败: %s", config.Conf.Users[index].Key(), err.Error())
+	}
+	return instance, nil
+}
+
+func usersTest(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: users test <账号>")
+	}
+	instance, err := loginUser(args[0])
+	if err != nil {
+		return err
+	}
+	err = instance.GetCourses()
+	if err != nil {
+		return fmt.Errorf("%s 获取课程失败: %s", instance.User.Key(), err.Error())
+	}
+	fmt.Printf("%s 登录成功, 共 %d 门课程\n", instance.User.Key(), len(instance.Courses))
+	return nil
+}
+
+func coursesCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: courses <账号>")
+	}
+	instance, err := loginUser(args[0])
+	if err != nil {
+		return err
+	}
+	err = instance.GetCourses()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\t课程\t进度\t视频\t状态\t结束时间\t分类\t学院\t选课规则")
+	for _, course := range instance.Courses {
+		rule := "学习"
+		if reason := task.ExcludeReason(instance.User, course); reason != "" {
+			rule = "排除: " + reason
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\t%s\n", course.ID, course.Name, course.Progress1,
+			course.VideoLearned, course.VideoCount, courseState(course.Progress, course.State), course.EndDate,
+			course.CategoryName, course.CollegeName, rule)
+	}
+	return writer.Flush()
+}
+
+func courseState(progress float32, state int) string {
+	switch {
+	case progress == 1:
+		return "已完成"
+	case state == 2:
+		return "已结束"
+	case progress > 0:
+		return "进行中"
+	}
+	return "未开始"
+}
@@ -3,20 +3,23 @@ module github.com/aoaostar/mooc
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/EDDYCJY/fake-useragent v0.2.0
 	github.com/go-resty/resty/v2 v2.7.0
+	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
+	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
 	github.com/sirupsen/logrus v1.9.0
+	go.etcd.io/bbolt v1.3.8
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/BurntSushi/toml v1.2.1 // indirect
-	github.com/EDDYCJY/fake-useragent v0.2.0 // indirect
 	github.com/PuerkitoBio/goquery v1.8.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
-	github.com/stretchr/testify v1.8.0 // indirect
+	github.com/stretchr/testify v1.8.1 // indirect
 	golang.org/x/net v0.0.0-20221014081412-f15817d10f9b // indirect
-	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
 )
 
-replace golang.org/x/sys => golang.org/x/sys v0.15.0
\ No newline at end of file
+replace golang.org/x/sys => golang.org/x/sys v0.15.0
@@ -1,10 +1,17 @@
+//go:build windows
+// +build windows
+
 package gui
 
 import (
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/aoaostar/mooc/pkg/captcha"
+	"github.com/aoaostar/mooc/pkg/todo"
 	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/config"
 	"github.com/sirupsen/logrus"
 	"sync"
 	"time"
@@ -18,16 +25,16 @@ type App struct {
 	ProcessMonitoringView *ProcessMonitoringView
 	UserManagementView  *UserManagementView
 	ConfigSettingsView  *ConfigSettingsView
-	ConfigManager       *ConfigManager
+	ConfigManager       *bootstrap.ConfigManager
 	
 	mu                  sync.Mutex
 }
 
 // 创建并运行应用程序
 func RunApp() error {
-	// 初始化配置管理器
-	configPath := "./config.json"
-	configManager := NewConfigManager(configPath)
+	// 初始化配置管理器, 路径可由 --config 或 MOOC_CONFIG 指定
+	configPath, _ := config.ResolvePath(config.LoadOptions.Path)
+	configManager := bootstrap.NewConfigManager(configPath)
 	
 	// 创建应用实例
 	app := &App{
@@ -97,6 +104,12 @@ func RunApp() error {
 	// 注册为日志观察者
 	bootstrap.RegisterLogObserver(app)
 	
+	// 注册为服务器熔断观察者
+	bootstrap.RegisterBreakerObserver(app)
+	
+	// 需要手动输入验证码时弹出对话框
+	captcha.Subscribe(app.OnCaptcha)
+	
 	// 初始化核心引擎
 	go func() {
 		// 启动核心引擎，但不启动Web服务
@@ -123,14 +136,14 @@ func RunApp() error {
 }
 
 // 实现TaskStatusObserver接口
-func (app *App) OnTaskStatusChanged(task task.Task, status string, progress float64) {
+func (app *App) OnTaskStatusChanged(task task.Task, status task.Status, progress float64, reason string) {
 	app.mu.Lock()
 	defer app.mu.Unlock()
 	
 	// 确保在UI线程中执行
 	walk.MustDo(func() {
 		if app.ProcessMonitoringView != nil {
-			app.ProcessMonitoringView.OnTaskStatusChanged(task, status, progress)
+			app.ProcessMonitoringView.OnTaskStatusChanged(task, status, progress, reason)
 		}
 	})
 }
@@ -159,6 +172,43 @@ func (app *App) OnTaskError(task task.Task, err error) {
 	})
 }
 
+func (app *App) OnTaskEstimate(task task.Task, remaining time.Duration, eta time.Time) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	
+	// 确保在UI线程中执行
+	walk.MustDo(func() {
+		if app.ProcessMonitoringView != nil {
+			app.ProcessMonitoringView.OnTaskEstimate(task, remaining, eta)
+		}
+	})
+}
+
+func (app *App) OnTaskTodo(task task.Task, items []todo.Item) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	
+	// 确保在UI线程中执行
+	walk.MustDo(func() {
+		if app.ProcessMonitoringView != nil {
+			app.ProcessMonitoringView.OnTaskTodo(task, items)
+		}
+	})
+}
+
+// 实现BreakerObserver接口
+func (app *App) OnBreakerChanged(change breaker.Change) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	
+	// 确保在UI线程中执行
+	walk.MustDo(func() {
+		if app.ProcessMonitoringView != nil {
+			app.ProcessMonitoringView.OnBreakerChanged(change)
+		}
+	})
+}
+
 // 实现LogObserver接口
 func (app *App) OnLogMessage(level, message string) {
 	app.mu.Lock()
@@ -0,0 +1,108 @@
+//go:build windows
+// +build windows
+
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/captcha"
+	"github.com/lxn/walk"
+	. "github.com/lxn/walk/declarative"
+	"github.com/sirupsen/logrus"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"sync"
+)
+
+// 正在显示的验证码对话框, key为验证码ID
+var captchaDialogs = struct {
+	sync.Mutex
+	data map[string]*walk.Dialog
+}{data: map[string]*walk.Dialog{}}
+
+// OnCaptcha 需要手动输入验证码时弹出对话框, 输入完成或超时后关闭
+func (app *App) OnCaptcha(event captcha.Event) {
+	walk.MustDo(func() {
+		if event.Closed {
+			captchaDialogs.Lock()
+			dialog, ok := captchaDialogs.data[event.Request.ID]
+			delete(captchaDialogs.data, event.Request.ID)
+			captchaDialogs.Unlock()
+			if ok {
+				dialog.Cancel()
+			}
+			return
+		}
+		if err := app.showCaptcha(event.Request); err != nil {
+			logrus.Errorf("显示验证码失败: %s", err.Error())
+		}
+	})
+}
+
+func (app *App) showCaptcha(request captcha.Request) error {
+	img, _, err := image.Decode(bytes.NewReader(request.Image))
+	if err != nil {
+		return err
+	}
+	bitmap, err := walk.NewBitmapFromImageForDPI(img, 96)
+	if err != nil {
+		return err
+	}
+
+	var dialog *walk.Dialog
+	var codeEdit *walk.LineEdit
+	var acceptPB, cancelPB *walk.PushButton
+	err = Dialog{
+		AssignTo:      &dialog,
+		Title:         "请输入验证码",
+		DefaultButton: &acceptPB,
+		CancelButton:  &cancelPB,
+		MinSize:       Size{Width: 300, Height: 180},
+		Layout:        VBox{},
+		Children: []Widget{
+			Label{
+				Text: fmt.Sprintf("[%s] %s 前有效", request.User, request.Deadline.Format("15:04:05")),
+			},
+			ImageView{
+				Image: bitmap,
+				Mode:  ImageViewModeIdeal,
+			},
+			LineEdit{
+				AssignTo: &codeEdit,
+			},
+			Composite{
+				Layout: HBox{},
+				Children: []Widget{
+					HSpacer{},
+					PushButton{
+						AssignTo: &acceptPB,
+						Text:     "确定",
+						OnClicked: func() {
+							if !captcha.Submit(request.ID, codeEdit.Text()) {
+								walk.MsgBox(dialog, "提示", "验证码为空或已超时", walk.MsgBoxIconWarning)
+								return
+							}
+							dialog.Accept()
+						},
+					},
+					PushButton{
+						AssignTo:  &cancelPB,
+						Text:      "取消",
+						OnClicked: func() { dialog.Cancel() },
+					},
+				},
+			},
+		},
+	}.Create(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	captchaDialogs.Lock()
+	captchaDialogs.data[request.ID] = dialog
+	captchaDialogs.Unlock()
+	dialog.Show()
+	return nil
+}
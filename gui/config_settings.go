@@ -1,10 +1,15 @@
+//go:build windows
+// +build windows
+
 package gui
 
 import (
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
+	"github.com/aoaostar/mooc/bootstrap"
 	"github.com/aoaostar/mooc/pkg/config"
 	"github.com/sirupsen/logrus"
+	"strings"
 	"sync"
 )
 
@@ -16,6 +21,17 @@ type ConfigSettingsView struct {
 	limitEdit       *walk.NumberEdit
 	serverEdit      *walk.LineEdit
 	
+	// 运行参数
+	timeoutEdit      *walk.NumberEdit
+	retryCountEdit   *walk.NumberEdit
+	retryWaitEdit    *walk.NumberEdit
+	proxyEdit        *walk.LineEdit
+	userAgentEdit    *walk.LineEdit
+	maxCoursesEdit   *walk.NumberEdit
+	pollIntervalEdit *walk.NumberEdit
+	windowsEdit      *walk.LineEdit
+	effectiveLabel   *walk.Label
+	
 	// 按钮
 	saveButton      *walk.PushButton
 	resetButton     *walk.PushButton
@@ -23,13 +39,13 @@ type ConfigSettingsView struct {
 	exportButton    *walk.PushButton
 	
 	// 数据
-	configManager   *ConfigManager
+	configManager   *bootstrap.ConfigManager
 	
 	mu              sync.Mutex
 }
 
 // 创建配置设置页面
-func NewConfigSettingsPage(parent walk.Container, configManager *ConfigManager) (*ConfigSettingsView, error) {
+func NewConfigSettingsPage(parent walk.Container, configManager *bootstrap.ConfigManager) (*ConfigSettingsView, error) {
 	view := new(ConfigSettingsView)
 	view.configManager = configManager
 	
@@ -56,7 +72,80 @@ func NewConfigSettingsPage(parent walk.Container, configManager *ConfigManager)
 					Label{Text: "服务器地址:"},
 					LineEdit{
 						AssignTo: &view.serverEdit,
-						Text:     ":10086",
+						Text:     "127.0.0.1:10086",
+					},
+				},
+			},
+			
+			// 运行参数, 用户可以单独覆盖
+			GroupBox{
+				Title:  "运行参数 (0或留空使用默认值)",
+				Layout: Grid{Columns: 4},
+				Children: []Widget{
+					Label{Text: "请求超时(秒):"},
+					NumberEdit{
+						AssignTo: &view.timeoutEdit,
+						MinValue: 0,
+						MaxValue: 3600,
+						Decimals: 0,
+					},
+					
+					Label{Text: "进度轮询间隔(秒):"},
+					NumberEdit{
+						AssignTo: &view.pollIntervalEdit,
+						MinValue: 0,
+						MaxValue: 3600,
+						Decimals: 0,
+					},
+					
+					Label{Text: "重试次数(-1不重试):"},
+					NumberEdit{
+						AssignTo: &view.retryCountEdit,
+						MinValue: -1,
+						MaxValue: 100,
+						Decimals: 0,
+					},
+					
+					Label{Text: "重试等待(秒):"},
+					NumberEdit{
+						AssignTo: &view.retryWaitEdit,
+						MinValue: 0,
+						MaxValue: 3600,
+						Decimals: 0,
+					},
+					
+					Label{Text: "单账号并发课程数:"},
+					NumberEdit{
+						AssignTo: &view.maxCoursesEdit,
+						MinValue: 0,
+						MaxValue: 999999,
+						Decimals: 0,
+					},
+					
+					Label{Text: "代理地址:"},
+					LineEdit{
+						AssignTo: &view.proxyEdit,
+						CueBanner: "http://127.0.0.1:7890",
+					},
+					
+					Label{Text: "User-Agent:"},
+					LineEdit{
+						AssignTo: &view.userAgentEdit,
+						CueBanner: "留空随机生成",
+						ColumnSpan: 3,
+					},
+					
+					Label{Text: "学习时段:"},
+					LineEdit{
+						AssignTo: &view.windowsEdit,
+						CueBanner: "留空不限制, 多个时段用;分隔, 如 mon-fri 08:00-23:00; sat-sun 09:00-22:00",
+						ColumnSpan: 3,
+					},
+					
+					Label{Text: "生效设置:"},
+					Label{
+						AssignTo: &view.effectiveLabel,
+						ColumnSpan: 3,
 					},
 				},
 			},
@@ -114,6 +203,7 @@ func (v *ConfigSettingsView) loadConfig() {
 	walk.MustDo(func() {
 		v.limitEdit.SetValue(float64(conf.Global.Limit))
 		v.serverEdit.SetText(conf.Global.Server)
+		v.setSettings(conf.Global.Settings)
 	})
 }
 
@@ -127,6 +217,7 @@ func (v *ConfigSettingsView) onSave() {
 	// 更新全局配置
 	conf.Global.Limit = int(v.limitEdit.Value())
 	conf.Global.Server = v.serverEdit.Text()
+	conf.Global.Settings = v.getSettings(conf.Global.Settings)
 	
 	// 保存配置
 	err := v.configManager.SaveConfig(conf)
@@ -151,7 +242,8 @@ func (v *ConfigSettingsView) onReset() {
 	// 设置默认值
 	walk.MustDo(func() {
 		v.limitEdit.SetValue(3)
-		v.serverEdit.SetText(":10086")
+		v.serverEdit.SetText("127.0.0.1:10086")
+		v.setSettings(config.Settings{})
 	})
 }
 
@@ -162,7 +254,7 @@ func (v *ConfigSettingsView) onImport() {
 	
 	dlg := new(walk.FileDialog)
 	dlg.Title = "导入配置文件"
-	dlg.Filter = "JSON文件 (*.json)|*.json|所有文件 (*.*)|*.*"
+	dlg.Filter = "配置文件 (*.json;*.toml;*.yaml;*.yml)|*.json;*.toml;*.yaml;*.yml|JSON文件 (*.json)|*.json|TOML文件 (*.toml)|*.toml|YAML文件 (*.yaml;*.yml)|*.yaml;*.yml|所有文件 (*.*)|*.*"
 	
 	if ok, err := dlg.ShowOpen(v.Form()); err != nil {
 		walk.MsgBox(v.Form(), "错误", "打开文件对话框失败: "+err.Error(), walk.MsgBoxIconError)
@@ -191,7 +283,7 @@ func (v *ConfigSettingsView) onExport() {
 	
 	dlg := new(walk.FileDialog)
 	dlg.Title = "导出配置文件"
-	dlg.Filter = "JSON文件 (*.json)|*.json|所有文件 (*.*)|*.*"
+	dlg.Filter = "配置文件 (*.json;*.toml;*.yaml;*.yml)|*.json;*.toml;*.yaml;*.yml|JSON文件 (*.json)|*.json|TOML文件 (*.toml)|*.toml|YAML文件 (*.yaml;*.yml)|*.yaml;*.yml|所有文件 (*.*)|*.*"
 	
 	if ok, err := dlg.ShowSave(v.Form()); err != nil {
 		walk.MsgBox(v.Form(), "错误", "打开文件对话框失败: "+err.Error(), walk.MsgBoxIconError)
@@ -209,3 +301,41 @@ func (v *ConfigSettingsView) onExport() {
 	
 	walk.MsgBox(v.Form(), "成功", "配置已导出到: "+dlg.FilePath, walk.MsgBoxIconInformation)
 }
+
+// 显示运行参数
+func (v *ConfigSettingsView) setSettings(settings config.Settings) {
+	v.timeoutEdit.SetValue(float64(settings.Timeout))
+	v.retryCountEdit.SetValue(float64(settings.RetryCount))
+	v.retryWaitEdit.SetValue(float64(settings.RetryWait))
+	v.proxyEdit.SetText(settings.Proxy)
+	v.userAgentEdit.SetText(settings.UserAgent)
+	v.maxCoursesEdit.SetValue(float64(settings.MaxCourses))
+	v.pollIntervalEdit.SetValue(float64(settings.PollInterval))
+	v.windowsEdit.SetText(strings.Join(settings.Windows, "; "))
+	v.effectiveLabel.SetText(config.DefaultSettings().Merge(settings).String())
+}
+
+// 读取运行参数, 界面上没有的参数保留 base 中的值
+func (v *ConfigSettingsView) getSettings(base config.Settings) config.Settings {
+	settings := base
+	settings.Timeout = int(v.timeoutEdit.Value())
+	settings.RetryCount = int(v.retryCountEdit.Value())
+	settings.RetryWait = int(v.retryWaitEdit.Value())
+	settings.Proxy = v.proxyEdit.Text()
+	settings.UserAgent = v.userAgentEdit.Text()
+	settings.MaxCourses = int(v.maxCoursesEdit.Value())
+	settings.PollInterval = int(v.pollIntervalEdit.Value())
+	settings.Windows = splitWindows(v.windowsEdit.Text())
+	return settings
+}
+
+// 拆分以;分隔的学习时段
+func splitWindows(text string) []string {
+	var windows []string
+	for _, item := range strings.Split(text, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			windows = append(windows, item)
+		}
+	}
+	return windows
+}
@@ -1,21 +1,31 @@
+//go:build windows
+// +build windows
+
 package gui
 
 import (
+	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/util"
 	"github.com/lxn/walk"
 	"github.com/lxn/win"
 	"sync"
 )
 
-// 任务列表项
+// 任务列表项, 以任务标识 Key 区分, 同一课程在不同账号下是不同的任务
 type TaskItem struct {
 	ID            int
+	Key           string
+	Priority      int
 	CourseName    string
 	UserName      string
 	Progress      float64
-	Status        string
+	Status        task.Status
+	Reason        string
 	StartTime     time.Time
 	CurrentChapter string
 	CurrentLesson  string
+	Remaining     time.Duration
+	ETA           time.Time
 }
 
 // 任务列表模型
@@ -57,9 +67,19 @@ func (m *TaskListModel) Value(row, col int) interface{} {
 	case 2:
 		return fmt.Sprintf("%.0f%%", task.Progress*100)
 	case 3:
-		return task.Status
+		return task.Status.String()
 	case 4:
 		return task.StartTime.Format("15:04:05")
+	case 5:
+		if task.Remaining <= 0 {
+			return "-"
+		}
+		return util.FormatSeconds(int(task.Remaining.Seconds()))
+	case 6:
+		if task.Remaining <= 0 || task.ETA.IsZero() {
+			return "-"
+		}
+		return task.ETA.Format("01-02 15:04")
 	}
 	
 	return nil
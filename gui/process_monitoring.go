@@ -1,11 +1,19 @@
+//go:build windows
+// +build windows
+
 package gui
 
 import (
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 	"github.com/aoaostar/mooc/pkg/task"
+	taskpkg "github.com/aoaostar/mooc/pkg/task"
 	"github.com/aoaostar/mooc/bootstrap"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/aoaostar/mooc/pkg/breaker"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 	"sync"
 )
@@ -26,6 +34,7 @@ type ProcessMonitoringView struct {
 	statusLabel     *walk.Label
 	chapterLabel    *walk.Label
 	lessonLabel     *walk.Label
+	priorityEdit    *walk.NumberEdit
 	
 	// 日志显示
 	logView         *walk.TextEdit
@@ -35,6 +44,7 @@ type ProcessMonitoringView struct {
 	pauseAllButton  *walk.PushButton
 	stopAllButton   *walk.PushButton
 	globalStatusLabel *walk.Label
+	breakerLabel    *walk.Label
 	
 	// 数据
 	mu              sync.Mutex
@@ -67,6 +77,8 @@ func NewProcessMonitoringPage(parent walk.Container) (*ProcessMonitoringView, er
 							{Title: "进度", Width: 80},
 							{Title: "状态", Width: 80},
 							{Title: "开始时间", Width: 120},
+							{Title: "剩余时间", Width: 100},
+							{Title: "预计完成", Width: 100},
 						},
 						OnCurrentIndexChanged: view.onTaskSelected,
 						StyleCell: func(style *walk.CellStyle) {
@@ -78,12 +90,14 @@ func NewProcessMonitoringPage(parent walk.Container) (*ProcessMonitoringView, er
 							task := view.taskModel.tasks[style.Row()]
 							if style.Column() == 3 { // 状态列
 								switch task.Status {
-								case "已完成":
+								case taskpkg.StatusDone:
 									style.TextColor = walk.RGB(0, 128, 0) // 绿色
-								case "错误":
+								case taskpkg.StatusFailed:
 									style.TextColor = walk.RGB(255, 0, 0) // 红色
-								case "进行中":
+								case taskpkg.StatusRunning:
 									style.TextColor = walk.RGB(0, 0, 255) // 蓝色
+								case taskpkg.StatusPaused, taskpkg.StatusWaiting:
+									style.TextColor = walk.RGB(255, 165, 0) // 橙色
 								}
 							}
 						},
@@ -113,6 +127,24 @@ func NewProcessMonitoringPage(parent walk.Container) (*ProcessMonitoringView, er
 					
 					Label{Text: "当前课时:"},
 					Label{AssignTo: &view.lessonLabel},
+					
+					Label{Text: "优先级:"},
+					Composite{
+						Layout: HBox{MarginsZero: true},
+						Children: []Widget{
+							NumberEdit{
+								AssignTo: &view.priorityEdit,
+								Decimals: 0,
+								MinValue: -100,
+								MaxValue: 100,
+								ToolTipText: "数值越大越先学习, 只影响还在排队的任务",
+							},
+							PushButton{
+								Text:      "设置",
+								OnClicked: view.onSetPriority,
+							},
+						},
+					},
 				},
 			},
 			
@@ -152,7 +184,14 @@ func NewProcessMonitoringPage(parent walk.Container) (*ProcessMonitoringView, er
 						OnClicked: view.onStopAll,
 						MinSize: Size{Width: 100, Height: 30},
 					},
+					PushButton{
+						Text: "运行结果",
+						OnClicked: view.onShowSummary,
+						MinSize: Size{Width: 100, Height: 30},
+					},
 					HSpacer{},
+					Label{Text: "服务器:"},
+					Label{AssignTo: &view.breakerLabel, Text: "全部正常"},
 					Label{Text: "状态:"},
 					Label{AssignTo: &view.globalStatusLabel, Text: "就绪"},
 				},
@@ -189,9 +228,40 @@ func (v *ProcessMonitoringView) onTaskSelected() {
 	v.courseNameLabel.SetText(task.CourseName)
 	v.userNameLabel.SetText(task.UserName)
 	v.progressBar.SetValue(int(task.Progress * 100))
-	v.statusLabel.SetText(task.Status)
+	v.statusLabel.SetText(describeStatus(task.Status, task.Reason))
 	v.chapterLabel.SetText(task.CurrentChapter)
 	v.lessonLabel.SetText(task.CurrentLesson)
+	v.priorityEdit.SetValue(float64(task.Priority))
+}
+
+// 状态和原因
+func describeStatus(status taskpkg.Status, reason string) string {
+	if reason == "" {
+		return status.String()
+	}
+	return status.String() + ": " + reason
+}
+
+// 任务的手动优先级, 观察者方法的参数名与task包同名, 单独封装
+func taskPriority(t task.Task) int {
+	return task.Priority(t)
+}
+
+// 设置选中任务的优先级
+func (v *ProcessMonitoringView) onSetPriority() {
+	index := v.taskListView.CurrentIndex()
+	if index < 0 {
+		return
+	}
+	item := v.taskModel.tasks[index]
+	if !task.SetPriority(item.Key, int(v.priorityEdit.Value())) {
+		walk.MsgBox(v.Form(), "提示", "任务不存在", walk.MsgBoxIconWarning)
+	}
+}
+
+// 显示运行结果, 运行中显示当前的统计
+func (v *ProcessMonitoringView) onShowSummary() {
+	walk.MsgBox(v.Form(), "运行结果", bootstrap.Summary().Table(), walk.MsgBoxIconInformation)
 }
 
 // 开始全部任务
@@ -216,7 +286,7 @@ func (v *ProcessMonitoringView) onStopAll() {
 }
 
 // 实现TaskStatusObserver接口
-func (v *ProcessMonitoringView) OnTaskStatusChanged(task task.Task, status string, progress float64) {
+func (v *ProcessMonitoringView) OnTaskStatusChanged(task task.Task, status taskpkg.Status, progress float64, reason string) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
@@ -225,10 +295,12 @@ func (v *ProcessMonitoringView) OnTaskStatusChanged(task task.Task, status strin
 		// 查找任务是否已存在
 		found := false
 		for i, t := range v.taskModel.tasks {
-			if t.ID == task.Course.ID {
+			if t.Key == task.Key() {
 				// 更新现有任务
 				v.taskModel.tasks[i].Status = status
+				v.taskModel.tasks[i].Reason = reason
 				v.taskModel.tasks[i].Progress = progress
+				v.taskModel.tasks[i].Priority = taskPriority(task)
 				v.taskModel.PublishRowChanged(i)
 				found = true
 				break
@@ -239,10 +311,13 @@ func (v *ProcessMonitoringView) OnTaskStatusChanged(task task.Task, status strin
 		if !found {
 			v.taskModel.AddTask(TaskItem{
 				ID:         task.Course.ID,
+				Key:        task.Key(),
+				Priority:   taskPriority(task),
 				CourseName: task.Course.Name,
 				UserName:   task.User.Username,
 				Progress:   progress,
 				Status:     status,
+				Reason:     reason,
 				StartTime:  time.Now(),
 				CurrentChapter: "",
 				CurrentLesson: "",
@@ -252,21 +327,68 @@ func (v *ProcessMonitoringView) OnTaskStatusChanged(task task.Task, status strin
 		// 如果当前选中的是这个任务，更新详情视图
 		if v.taskListView.CurrentIndex() >= 0 {
 			currentTask := v.taskModel.tasks[v.taskListView.CurrentIndex()]
-			if currentTask.ID == task.Course.ID {
+			if currentTask.Key == task.Key() {
 				v.progressBar.SetValue(int(progress * 100))
-				v.statusLabel.SetText(status)
+				v.statusLabel.SetText(describeStatus(status, reason))
 			}
 		}
 	})
 }
 
+// 状态已经由 OnTaskStatusChanged 更新, 这里只输出日志
 func (v *ProcessMonitoringView) OnTaskCompleted(task task.Task) {
-	v.OnTaskStatusChanged(task, "已完成", 1.0)
+	v.AppendLog("info", fmt.Sprintf("[%s] 课程[%s] 已完成", task.User.Username, task.Course.Name))
 }
 
 func (v *ProcessMonitoringView) OnTaskError(task task.Task, err error) {
-	v.OnTaskStatusChanged(task, "错误", task.Course.Progress)
-	v.AppendLog("error", err.Error())
+	v.AppendLog("error", fmt.Sprintf("[%s] 课程[%s] 失败: %s", task.User.Username, task.Course.Name, err.Error()))
+}
+
+// 课程结束学习时提醒需要手动完成的节点
+func (v *ProcessMonitoringView) OnTaskTodo(task task.Task, items []todo.Item) {
+	v.AppendLog("warn", fmt.Sprintf("[%s] 课程[%s] 还有 %d 个节点需要手动完成, 课程结束时间: %s", task.User.Username, task.Course.Name, len(items), task.Course.EndDate))
+	v.AppendLog("warn", "    注意: "+todo.Note)
+	for _, item := range items {
+		v.AppendLog("warn", fmt.Sprintf("    %s [%s](%s), 解锁时间: %s", item.Chapter, item.Node, item.Kind, todo.Unlock(item)))
+	}
+}
+
+// OnBreakerChanged 学校服务器熔断或恢复时输出日志并更新服务器状态
+func (v *ProcessMonitoringView) OnBreakerChanged(change breaker.Change) {
+	switch {
+	case change.To == breaker.StateOpen && change.From == breaker.StateClosed:
+		v.AppendLog("warn", fmt.Sprintf("[%s] 学校服务器无法访问, 已暂停该学校的任务: %v", change.Host, change.Err))
+	case change.To == breaker.StateClosed:
+		v.AppendLog("info", fmt.Sprintf("[%s] 学校服务器已恢复", change.Host))
+	}
+	var open []string
+	for host, status := range breaker.States() {
+		if status.State != breaker.StateClosed {
+			open = append(open, fmt.Sprintf("%s %s(第 %d 次)", host, status.State, status.Opened))
+		}
+	}
+	sort.Strings(open)
+	text := "全部正常"
+	if len(open) > 0 {
+		text = strings.Join(open, ", ")
+	}
+	v.breakerLabel.SetText(text)
+}
+
+func (v *ProcessMonitoringView) OnTaskEstimate(task task.Task, remaining time.Duration, eta time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	walk.MustDo(func() {
+		for i, t := range v.taskModel.tasks {
+			if t.Key == task.Key() {
+				v.taskModel.tasks[i].Remaining = remaining
+				v.taskModel.tasks[i].ETA = eta
+				v.taskModel.PublishRowChanged(i)
+				break
+			}
+		}
+	})
 }
 
 // 实现LogObserver接口
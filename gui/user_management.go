@@ -1,13 +1,22 @@
+//go:build windows
+// +build windows
+
 package gui
 
 import (
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
+	"github.com/aoaostar/mooc/bootstrap"
 	"github.com/aoaostar/mooc/pkg/config"
 	"github.com/aoaostar/mooc/pkg/yinghua"
 	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/report"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/aoaostar/mooc/pkg/util"
 	"github.com/sirupsen/logrus"
 	"strconv"
+	"bytes"
+	"path/filepath"
 	"fmt"
 	"errors"
 	"sync"
@@ -36,6 +45,7 @@ type UserManagementView struct {
 	nameEdit        *walk.LineEdit      // 新增：姓名
 	platformEdit    *walk.LineEdit      // 新增：平台
 	remarkEdit      *walk.TextEdit      // 新增：备注
+	settingsLabel   *walk.Label         // 生效的运行参数
 	
 	// 用户课程列表
 	courseListView  *walk.TableView
@@ -45,6 +55,8 @@ type UserManagementView struct {
 	getCourseButton *walk.PushButton
 	startButton     *walk.PushButton
 	viewButton      *walk.PushButton
+	reportButton    *walk.PushButton
+	todoButton      *walk.PushButton
 	
 	// 保存取消按钮
 	saveButton      *walk.PushButton
@@ -52,13 +64,13 @@ type UserManagementView struct {
 	
 	// 数据
 	currentUser     *config.User
-	configManager   *ConfigManager
+	configManager   *bootstrap.ConfigManager
 	
 	mu              sync.Mutex
 }
 
 // 创建用户管理页面
-func NewUserManagementPage(parent walk.Container, configManager *ConfigManager) (*UserManagementView, error) {
+func NewUserManagementPage(parent walk.Container, configManager *bootstrap.ConfigManager) (*UserManagementView, error) {
 	view := new(UserManagementView)
 	view.configManager = configManager
 	
@@ -195,6 +207,12 @@ func NewUserManagementPage(parent walk.Container, configManager *ConfigManager)
 								MinSize:  Size{Width: 300, Height: 60},
 								ColumnSpan: 3,
 							},
+							
+							Label{Text: "生效设置:"},
+							Label{
+								AssignTo: &view.settingsLabel,
+								ColumnSpan: 3,
+							},
 						},
 					},
 					
@@ -256,6 +274,18 @@ func NewUserManagementPage(parent walk.Container, configManager *ConfigManager)
 										OnClicked: view.onViewCourse,
 										MinSize: Size{Width: 100, Height: 30},
 									},
+									PushButton{
+										AssignTo: &view.reportButton,
+										Text:     "导出报告",
+										OnClicked: view.onExportReport,
+										MinSize: Size{Width: 100, Height: 30},
+									},
+									PushButton{
+										AssignTo: &view.todoButton,
+										Text:     "待办事项",
+										OnClicked: view.onShowTodos,
+										MinSize: Size{Width: 100, Height: 30},
+									},
 								},
 							},
 						},
@@ -347,6 +377,7 @@ func (v *UserManagementView) clearForm() {
 		v.nameEdit.SetText("")
 		v.platformEdit.SetText("")
 		v.remarkEdit.SetText("")
+		v.settingsLabel.SetText("")
 		v.courseModel.ClearCourses()
 	})
 }
@@ -403,6 +434,7 @@ func (v *UserManagementView) onUserSelected() {
 		v.nameEdit.SetText(user.Name)
 		v.platformEdit.SetText(user.Platform)
 		v.remarkEdit.SetText(user.Remark)
+		v.settingsLabel.SetText(v.configManager.GetConfig().Effective(user).String())
 		
 		// 更新按钮状态
 		v.editButton.SetEnabled(true)
@@ -562,10 +594,9 @@ func (v *UserManagementView) onStartCourse() {
 	}
 	
 	// 添加到任务队列
-	task.Tasks = append(task.Tasks, task.Task{
+	task.AddTask(task.Task{
 		User:   *v.currentUser,
 		Course: course,
-		Status: false,
 	})
 	
 	walk.MsgBox(v.Form(), "提示", "课程已添加到任务队列，请切换到进程监控页面查看进度。", walk.MsgBoxIconInformation)
@@ -591,6 +622,96 @@ func (v *UserManagementView) onViewCourse() {
 		walk.MsgBoxIconInformation)
 }
 
+// 显示需要手动完成的作业、考试等节点, 选中账号时只显示该账号, 否则显示全部账号
+func (v *UserManagementView) onShowTodos() {
+	users := v.configManager.GetConfig().Users
+	key := ""
+	if v.currentUser != nil {
+		users = []config.User{*v.currentUser}
+		key = v.currentUser.Key()
+	}
+	
+	// 查询期间禁用按钮, 避免重复查询
+	v.todoButton.SetEnabled(false)
+	go func() {
+		// 优先使用运行中的任务已经获取的章节, 没有任务时才登录账号获取
+		result, ok := bootstrap.Todos(key)
+		if !ok {
+			result = todo.Build(users)
+		}
+		var buffer bytes.Buffer
+		err := todo.Print(&buffer, result)
+		walk.MustDo(func() {
+			v.todoButton.SetEnabled(true)
+			if err != nil {
+				walk.MsgBox(v.Form(), "错误", "获取待办事项失败: "+err.Error(), walk.MsgBoxIconError)
+				return
+			}
+			walk.MsgBox(v.Form(), "待办事项", buffer.String(), walk.MsgBoxIconInformation)
+		})
+	}()
+}
+
+// 导出课程进度报告, 选中账号时只导出该账号, 否则导出全部账号
+func (v *UserManagementView) onExportReport() {
+	dlg := new(walk.FileDialog)
+	dlg.Title = "导出课程进度报告"
+	dlg.Filter = "HTML文件 (*.html)|*.html|Markdown文件 (*.md)|*.md|CSV文件 (*.csv)|*.csv"
+	dlg.FilePath = "report.html"
+	
+	if ok, err := dlg.ShowSave(v.Form()); err != nil {
+		walk.MsgBox(v.Form(), "错误", "打开文件对话框失败: "+err.Error(), walk.MsgBoxIconError)
+		return
+	} else if !ok {
+		return
+	}
+	
+	path := dlg.FilePath
+	if filepath.Ext(path) == "" {
+		extensions := []string{".html", ".md", ".csv"}
+		index := dlg.FilterIndex - 1
+		if index < 0 || index >= len(extensions) {
+			index = 0
+		}
+		path += extensions[index]
+	}
+	format, err := report.FormatOf(path)
+	if err != nil {
+		walk.MsgBox(v.Form(), "错误", err.Error(), walk.MsgBoxIconError)
+		return
+	}
+	
+	users := v.configManager.GetConfig().Users
+	key := ""
+	if v.currentUser != nil {
+		users = []config.User{*v.currentUser}
+		key = v.currentUser.Key()
+	}
+	
+	// 生成期间禁用按钮, 避免重复导出
+	v.reportButton.SetEnabled(false)
+	go func() {
+		// 优先使用运行中的任务已经获取的课程, 没有任务时才登录账号获取
+		result, ok := bootstrap.Report(key)
+		if !ok {
+			result = report.Build(users)
+		}
+		var buffer bytes.Buffer
+		err := report.Write(&buffer, format, result)
+		if err == nil {
+			err = util.WriteFileAtomic(path, buffer.Bytes(), 0644, 0)
+		}
+		walk.MustDo(func() {
+			v.reportButton.SetEnabled(true)
+			if err != nil {
+				walk.MsgBox(v.Form(), "错误", "导出报告失败: "+err.Error(), walk.MsgBoxIconError)
+				return
+			}
+			walk.MsgBox(v.Form(), "成功", "报告已导出到: "+path, walk.MsgBoxIconInformation)
+		})
+	}()
+}
+
 // 保存
 func (v *UserManagementView) onSave() {
 	// 验证表单
@@ -620,6 +741,12 @@ func (v *UserManagementView) onSave() {
 		Remark:    v.remarkEdit.Text(),
 	}
 	
+	// 保留配置文件中单独设置的运行参数和选课规则
+	if v.currentUser != nil {
+		user.Settings = v.currentUser.Settings
+		user.Courses = v.currentUser.Courses
+	}
+	
 	// 获取当前配置
 	conf := v.configManager.GetConfig()
 	
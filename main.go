@@ -1,13 +1,14 @@
 package main
 
 import (
-	"github.com/aoaostar/mooc/gui"
+	"github.com/aoaostar/mooc/cmd"
 	"github.com/sirupsen/logrus"
+	"os"
 )
 
 func main() {
-	// 运行GUI应用程序
-	err := gui.RunApp()
+	// 解析命令行, 没有子命令时执行默认动作: windows运行GUI, 其他系统直接开始学习
+	err := cmd.Execute(os.Args[1:], defaultAction)
 	if err != nil {
 		logrus.Fatal(err)
 	}
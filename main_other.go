@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "github.com/aoaostar/mooc/cmd"
+
+// defaultAction 没有GUI, 等同于 run 命令
+func defaultAction() error {
+	return cmd.Run(nil)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "github.com/aoaostar/mooc/gui"
+
+// defaultAction 运行GUI应用程序
+var defaultAction = gui.RunApp
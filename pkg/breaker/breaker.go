@@ -0,0 +1,253 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	StateClosed   = "正常"
+	StateOpen     = "熔断"
+	StateHalfOpen = "探测中"
+)
+
+// Change 熔断器状态变更
+type Change struct {
+	Host string
+	From string
+	To   string
+	Err  error
+	Time time.Time
+}
+
+// Status 熔断器的当前状态, 供状态查询使用
+type Status struct {
+	State string `json:"state"`
+	// Since 进入当前状态的时间
+	Since     time.Time `json:"since"`
+	Failures  int       `json:"failures"`
+	Threshold int       `json:"threshold"`
+	// Interval 熔断后探测的间隔, 秒
+	Interval int `json:"interval"`
+	// Opened 熔断的次数
+	Opened    int    `json:"opened"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Breaker 单个平台域名的熔断器
+// 连续失败 threshold 次后熔断, 熔断期间所有请求等待, 每隔 interval 用 probe 探测一次, 探测成功后恢复
+type Breaker struct {
+	mu        sync.Mutex
+	host      string
+	threshold int
+	interval  time.Duration
+	probe     func() error
+	state     string
+	since     time.Time
+	failures  int
+	opened    int
+	lastError error
+	recovered chan struct{}
+}
+
+var breakers = struct {
+	sync.Mutex
+	data      map[string]*Breaker
+	listeners []func(Change)
+}{data: map[string]*Breaker{}}
+
+// For 返回 host 共用的熔断器, threshold <= 0 表示该调用方不熔断
+// 同一域名的账号设置不同时取最严格的设置: 最小的正数阈值和最长的探测间隔
+// probe 不应依赖某个账号的会话或客户端, 同一域名只保留第一个
+func For(host string, threshold int, interval time.Duration, probe func() error) *Breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.data[host]
+	if !ok {
+		b = &Breaker{
+			host:      host,
+			threshold: threshold,
+			interval:  interval,
+			probe:     probe,
+			state:     StateClosed,
+			since:     time.Now(),
+		}
+		breakers.data[host] = b
+		return b
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if threshold > 0 && (b.threshold <= 0 || threshold < b.threshold) {
+		b.threshold = threshold
+	}
+	if interval > b.interval {
+		b.interval = interval
+	}
+	if b.probe == nil {
+		b.probe = probe
+	}
+	return b
+}
+
+// Lookup 返回 host 的熔断器, 不存在时返回nil
+func Lookup(host string) *Breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+	return breakers.data[host]
+}
+
+// Subscribe 订阅熔断器状态变更
+func Subscribe(listener func(Change)) {
+	breakers.Lock()
+	defer breakers.Unlock()
+	breakers.listeners = append(breakers.listeners, listener)
+}
+
+func publish(change Change) {
+	breakers.Lock()
+	listeners := breakers.listeners
+	breakers.Unlock()
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// Host 平台域名
+func (b *Breaker) Host() string {
+	return b.host
+}
+
+// State 当前状态
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Status 当前状态和统计
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := Status{
+		State:     b.state,
+		Since:     b.since,
+		Failures:  b.failures,
+		Threshold: b.threshold,
+		Interval:  int(b.interval / time.Second),
+		Opened:    b.opened,
+	}
+	if b.lastError != nil {
+		status.LastError = b.lastError.Error()
+	}
+	return status
+}
+
+// Open 是否处于熔断状态
+func (b *Breaker) Open() bool {
+	return b.State() != StateClosed
+}
+
+// Success 请求成功, 清零连续失败次数
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// Failure 请求失败, 连续失败次数达到阈值时熔断并开始探测
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	if b.threshold <= 0 || b.state != StateClosed {
+		b.mu.Unlock()
+		return
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		b.mu.Unlock()
+		return
+	}
+	b.state = StateOpen
+	b.since = time.Now()
+	b.opened++
+	b.lastError = err
+	b.recovered = make(chan struct{})
+	b.mu.Unlock()
+
+	publish(Change{Host: b.host, From: StateClosed, To: StateOpen, Err: err, Time: time.Now()})
+	go b.probing()
+}
+
+// Wait 熔断期间等待恢复, ctx 为nil时不会中断
+func (b *Breaker) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	if b.state == StateClosed {
+		b.mu.Unlock()
+		return nil
+	}
+	recovered := b.recovered
+	b.mu.Unlock()
+	if ctx == nil {
+		<-recovered
+		return nil
+	}
+	select {
+	case <-recovered:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// probing 熔断后定时探测, 成功后恢复
+func (b *Breaker) probing() {
+	for {
+		b.mu.Lock()
+		interval, probe := b.interval, b.probe
+		b.mu.Unlock()
+		time.Sleep(interval)
+		b.transition(StateOpen, StateHalfOpen, nil)
+		err := probe()
+		if err == nil {
+			b.mu.Lock()
+			b.failures = 0
+			recovered := b.recovered
+			b.mu.Unlock()
+			b.transition(StateHalfOpen, StateClosed, nil)
+			close(recovered)
+			return
+		}
+		b.transition(StateHalfOpen, StateOpen, err)
+	}
+}
+
+func (b *Breaker) transition(from, to string, err error) {
+	b.mu.Lock()
+	if b.state != from {
+		b.mu.Unlock()
+		return
+	}
+	b.state = to
+	b.since = time.Now()
+	if err != nil {
+		b.lastError = err
+	}
+	b.mu.Unlock()
+	publish(Change{Host: b.host, From: from, To: to, Err: err, Time: time.Now()})
+}
+
+// States 全部平台域名的熔断器状态
+func States() map[string]Status {
+	breakers.Lock()
+	list := make([]*Breaker, 0, len(breakers.data))
+	for _, b := range breakers.data {
+		list = append(list, b)
+	}
+	breakers.Unlock()
+	states := make(map[string]Status, len(list))
+	for _, b := range list {
+		states[b.host] = b.Status()
+	}
+	return states
+}
@@ -0,0 +1,66 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForAppliesStrictestSettings(t *testing.T) {
+	var first, second int32
+	b := For("strict.example.com", 3, 10*time.Millisecond, func() error {
+		atomic.AddInt32(&first, 1)
+		return nil
+	})
+	For("strict.example.com", 2, 30*time.Millisecond, func() error {
+		atomic.AddInt32(&second, 1)
+		return nil
+	})
+	For("strict.example.com", -1, time.Millisecond, nil)
+
+	status := b.Status()
+	if status.Threshold != 2 || status.State != StateClosed {
+		t.Fatalf("应取最小的阈值, 得到 %+v", status)
+	}
+	b.mu.Lock()
+	interval := b.interval
+	b.mu.Unlock()
+	if interval != 30*time.Millisecond {
+		t.Fatalf("应取最长的探测间隔, 得到 %s", interval)
+	}
+
+	failure := errors.New("连接被拒绝")
+	b.Failure(failure)
+	if b.Open() {
+		t.Fatal("未达到阈值时不应熔断")
+	}
+	b.Failure(failure)
+	status = b.Status()
+	if status.State != StateOpen || status.Opened != 1 || status.LastError != failure.Error() {
+		t.Fatalf("达到阈值后应熔断, 得到 %+v", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("探测成功后应恢复: %v", err)
+	}
+	if got := States()["strict.example.com"]; got.State != StateClosed || got.Failures != 0 {
+		t.Fatalf("恢复后的状态不正确: %+v", got)
+	}
+	if atomic.LoadInt32(&first) != 1 || atomic.LoadInt32(&second) != 0 {
+		t.Fatalf("应使用第一个探测函数, 调用次数 %d, %d", first, second)
+	}
+}
+
+func TestForDisabledThreshold(t *testing.T) {
+	b := For("disabled.example.com", -1, time.Millisecond, func() error { return nil })
+	for i := 0; i < 10; i++ {
+		b.Failure(errors.New("失败"))
+	}
+	if b.Open() {
+		t.Fatal("threshold <= 0 时不应熔断")
+	}
+}
@@ -0,0 +1,22 @@
+package captcha
+
+import (
+	"context"
+	"time"
+)
+
+// Request 待识别的验证码
+type Request struct {
+	ID   string `json:"id"`
+	User string `json:"user"`
+	// Image 验证码图片, 通过 /api/captcha/image 获取
+	Image    []byte    `json:"-"`
+	Created  time.Time `json:"created"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// Solver 验证码识别方式
+type Solver interface {
+	// Solve 识别验证码, ctx 取消或超时后返回错误
+	Solve(ctx context.Context, request Request) (string, error)
+}
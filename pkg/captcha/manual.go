@@ -0,0 +1,131 @@
+package captcha
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event 手动输入的验证码出现或结束
+type Event struct {
+	Request Request
+	// Closed 已输入、超时或学习被中断
+	Closed bool
+}
+
+// 等待手动输入的验证码, key为验证码ID
+var pending = struct {
+	sync.Mutex
+	seq       int
+	data      map[string]*waiting
+	listeners []func(Event)
+}{data: map[string]*waiting{}}
+
+type waiting struct {
+	request Request
+	answer  chan string
+}
+
+// Manual 由用户在GUI、命令行或web端查看图片后手动输入
+type Manual struct {
+	// Timeout 等待输入的时间
+	Timeout time.Duration
+}
+
+// Solve 登记验证码并等待用户输入
+func (m Manual) Solve(ctx context.Context, request Request) (string, error) {
+	now := time.Now()
+	item := &waiting{answer: make(chan string, 1)}
+	pending.Lock()
+	pending.seq++
+	request.ID = strconv.Itoa(pending.seq)
+	request.Created = now
+	request.Deadline = now.Add(m.Timeout)
+	item.request = request
+	pending.data[request.ID] = item
+	pending.Unlock()
+
+	notify(Event{Request: request})
+	defer func() {
+		pending.Lock()
+		delete(pending.data, request.ID)
+		pending.Unlock()
+		notify(Event{Request: request, Closed: true})
+	}()
+
+	timer := time.NewTimer(m.Timeout)
+	defer timer.Stop()
+	select {
+	case code := <-item.answer:
+		return code, nil
+	case <-timer.C:
+		return "", fmt.Errorf("%s 内没有输入验证码", m.Timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func notify(event Event) {
+	pending.Lock()
+	listeners := pending.listeners
+	pending.Unlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// Subscribe 订阅需要手动输入的验证码
+func Subscribe(listener func(Event)) {
+	pending.Lock()
+	defer pending.Unlock()
+	pending.listeners = append(pending.listeners, listener)
+}
+
+// Pending 等待输入的验证码, 按出现顺序排列
+func Pending() []Request {
+	pending.Lock()
+	defer pending.Unlock()
+	requests := make([]Request, 0, len(pending.data))
+	for _, item := range pending.data {
+		requests = append(requests, item.request)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Created.Before(requests[j].Created)
+	})
+	return requests
+}
+
+// Lookup 查找等待输入的验证码
+func Lookup(id string) (Request, bool) {
+	pending.Lock()
+	defer pending.Unlock()
+	item, ok := pending.data[id]
+	if !ok {
+		return Request{}, false
+	}
+	return item.request, true
+}
+
+// Submit 提交用户输入的验证码, 验证码不存在或已经输入过时返回 false
+func Submit(id, code string) bool {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false
+	}
+	pending.Lock()
+	defer pending.Unlock()
+	item, ok := pending.data[id]
+	if !ok {
+		return false
+	}
+	select {
+	case item.answer <- code:
+		return true
+	default:
+		return false
+	}
+}
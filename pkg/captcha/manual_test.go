@@ -0,0 +1,101 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// solveAsync 在后台识别验证码, 返回识别结果和出现的验证码
+func solveAsync(ctx context.Context, solver Manual, user string) (<-chan error, <-chan string, <-chan Request) {
+	requests := make(chan Request, 1)
+	Subscribe(func(event Event) {
+		if event.Request.User == user && !event.Closed {
+			requests <- event.Request
+		}
+	})
+	errs := make(chan error, 1)
+	codes := make(chan string, 1)
+	go func() {
+		code, err := solver.Solve(ctx, Request{User: user, Image: []byte("png")})
+		codes <- code
+		errs <- err
+	}()
+	return errs, codes, requests
+}
+
+func waitRequest(t *testing.T, requests <-chan Request) Request {
+	t.Helper()
+	select {
+	case request := <-requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有出现需要输入的验证码")
+	}
+	return Request{}
+}
+
+func assertNotPending(t *testing.T, id string) {
+	t.Helper()
+	if _, ok := Lookup(id); ok {
+		t.Fatalf("结束后验证码 %s 应从等待列表中删除", id)
+	}
+}
+
+func TestManualSubmit(t *testing.T) {
+	errs, codes, requests := solveAsync(context.Background(), Manual{Timeout: time.Minute}, "manual-submit")
+	request := waitRequest(t, requests)
+	if found, ok := Lookup(request.ID); !ok || string(found.Image) != "png" {
+		t.Fatalf("应能查到等待输入的验证码, 得到 %+v", found)
+	}
+	if Submit(request.ID, "  ") {
+		t.Fatal("空的验证码不应提交成功")
+	}
+	if !Submit(request.ID, " ab12 ") {
+		t.Fatal("提交验证码失败")
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if code := <-codes; code != "ab12" {
+		t.Fatalf("验证码为 %q, 应为 ab12", code)
+	}
+	assertNotPending(t, request.ID)
+	if Submit(request.ID, "ab12") {
+		t.Fatal("已经结束的验证码不应提交成功")
+	}
+}
+
+func TestManualTimeout(t *testing.T) {
+	errs, _, requests := solveAsync(context.Background(), Manual{Timeout: 20 * time.Millisecond}, "manual-timeout")
+	request := waitRequest(t, requests)
+	if !request.Deadline.Equal(request.Created.Add(20 * time.Millisecond)) {
+		t.Fatalf("截止时间为 %s, 应为出现后20ms", request.Deadline)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("超时后应返回错误")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("超时后没有返回")
+	}
+	assertNotPending(t, request.ID)
+}
+
+func TestManualCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs, _, requests := solveAsync(ctx, Manual{Timeout: time.Minute}, "manual-cancel")
+	request := waitRequest(t, requests)
+	cancel()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("取消后应返回 context.Canceled, 得到 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后没有返回")
+	}
+	assertNotPending(t, request.ID)
+}
@@ -0,0 +1,43 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"github.com/go-resty/resty/v2"
+)
+
+// Remote 上传图片到第三方识别服务, 图片会离开本机, 需要在配置中明确开启
+type Remote struct {
+	URL    string
+	Client *resty.Client
+}
+
+// Solve 上传验证码图片, 识别服务返回 {"status":"ok","data":"验证码"}
+func (r Remote) Solve(ctx context.Context, request Request) (string, error) {
+	resp := new(types.Captcha)
+	response, err := r.Client.R().
+		SetContext(ctx).
+		SetFileReader("file", "image.png", bytes.NewReader(request.Image)).
+		SetResult(resp).
+		Post(r.URL)
+	if err != nil {
+		return "", err
+	}
+	if response.IsError() {
+		return "", fmt.Errorf("识别服务返回 %s", response.Status())
+	}
+	if resp.Status != "ok" {
+		if resp.Message == "" {
+			return "", errors.New("识别失败")
+		}
+		return "", errors.New(resp.Message)
+	}
+	code, ok := resp.Data.(string)
+	if !ok || code == "" {
+		return "", fmt.Errorf("识别结果格式错误: %v", resp.Data)
+	}
+	return code, nil
+}
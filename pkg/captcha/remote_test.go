@@ -0,0 +1,50 @@
+package captcha
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestRemoteSolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		code   string
+		ok     bool
+	}{
+		{name: "识别成功", status: 200, body: `{"status":"ok","data":"x7k2"}`, code: "x7k2", ok: true},
+		{name: "识别失败", status: 200, body: `{"status":"error","message":"图片无法识别"}`},
+		{name: "结果格式错误", status: 200, body: `{"status":"ok","data":123}`},
+		{name: "服务出错", status: 502, body: `{}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var uploaded string
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if file, _, err := request.FormFile("file"); err == nil {
+					data, _ := io.ReadAll(file)
+					uploaded = string(data)
+				}
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(test.status)
+				_, _ = io.WriteString(writer, test.body)
+			}))
+			defer server.Close()
+
+			solver := Remote{URL: server.URL, Client: resty.New()}
+			code, err := solver.Solve(context.Background(), Request{Image: []byte("png")})
+			if (err == nil) != test.ok || code != test.code {
+				t.Fatalf("识别结果为 %q/%v, 应为 %q/成功=%v", code, err, test.code, test.ok)
+			}
+			if uploaded != "png" {
+				t.Fatalf("上传的图片为 %q, 应为验证码图片", uploaded)
+			}
+		})
+	}
+}
@@ -1,26 +1,59 @@
 package config
 
+import (
+	"net/url"
+	"strings"
+)
+
 type Config struct {
-	Global Global `json:"global"`
-	Users  []User `json:"users"`
+	Global Global `json:"global" toml:"global" yaml:"global"`
+	Users  []User `json:"users" toml:"users" yaml:"users"`
 }
 
 type Global struct {
-	Server string `json:"server"`
-	Limit  int    `json:"limit"`
+	Server string `json:"server" toml:"server" yaml:"server"`
+	Limit  int    `json:"limit" toml:"limit" yaml:"limit"`
+	// Token web端访问令牌, 设置后验证码、优先级等接口需要携带, 为空时这些接口只允许本机访问
+	Token string `json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty"`
+	// Settings 所有账号的默认运行参数
+	Settings Settings `json:"settings" toml:"settings" yaml:"settings"`
 }
 
 type User struct {
-	BaseURL  string `json:"base_url"`
-	SchoolID int    `json:"school_id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	BaseURL  string `json:"base_url" toml:"base_url" yaml:"base_url"`
+	SchoolID int    `json:"school_id" toml:"school_id" yaml:"school_id"`
+	Username string `json:"username" toml:"username" yaml:"username"`
+	Password string `json:"password" toml:"password" yaml:"password"`
 	// 新增字段
-	Name     string `json:"name"`      // 姓名
-	Platform string `json:"platform"`  // 平台
-	Remark   string `json:"remark"`    // 备注
+	Name     string `json:"name" toml:"name" yaml:"name"`             // 姓名
+	Platform string `json:"platform" toml:"platform" yaml:"platform"` // 平台
+	Remark   string `json:"remark" toml:"remark" yaml:"remark"`       // 备注
+	// Settings 覆盖 global.settings 中的运行参数
+	Settings Settings `json:"settings,omitempty" toml:"settings,omitempty" yaml:"settings,omitempty"`
+	// Courses 选课规则, 为空时学习全部课程
+	Courses CourseRules `json:"courses,omitempty" toml:"courses,omitempty" yaml:"courses,omitempty"`
+}
+
+// Key 账号唯一标识, 由用户名和平台域名组成
+func (u User) Key() string {
+	return u.Username + "@" + u.Host()
+}
+
+// Host 平台域名, 同一个学校的账号共用
+func (u User) Host() string {
+	host := u.BaseURL
+	if parse, err := url.Parse(u.BaseURL); err == nil && parse.Host != "" {
+		host = parse.Host
+	}
+	return strings.TrimRight(host, "/")
 }
 
 var Conf Config
 
+// DataDir 运行数据目录, 存放会话、断点等状态文件
+const DataDir = "./data"
+
+// ReportDir 运行报告目录
+const ReportDir = "./reports"
+
 const VERSION = "v1.3.2-GUI"
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+// 配置文件格式
+const (
+	FormatJSON = "json"
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+)
+
+// FileNames 自动查找时依次尝试的配置文件名
+var FileNames = []string{"config.json", "config.toml", "config.yaml", "config.yml"}
+
+// FormatOf 根据扩展名判断配置文件格式, 无法识别时按JSON处理
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Unmarshal 按文件扩展名解析配置
+func Unmarshal(path string, data []byte, v interface{}) error {
+	switch FormatOf(path) {
+	case FormatTOML:
+		return toml.Unmarshal(data, v)
+	case FormatYAML:
+		return yaml.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Marshal 按文件扩展名序列化配置
+func Marshal(path string, v interface{}) ([]byte, error) {
+	switch FormatOf(path) {
+	case FormatTOML:
+		var buffer bytes.Buffer
+		err := toml.NewEncoder(&buffer).Encode(v)
+		return buffer.Bytes(), err
+	case FormatYAML:
+		return yaml.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// Valid 校验数据能否按文件扩展名解析
+func Valid(path string, data []byte) error {
+	var raw interface{}
+	return Unmarshal(path, data, &raw)
+}
+
+// decodeRaw 解析为通用的 map, 用于判断配置项是否出现在文件中
+func decodeRaw(path string, data []byte) map[string]interface{} {
+	var raw interface{}
+	if err := Unmarshal(path, data, &raw); err != nil {
+		return nil
+	}
+	object, _ := normalize(raw).(map[string]interface{})
+	return object
+}
+
+// normalize yaml解析出的 map[interface{}]interface{} 转换为 map[string]interface{}
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = normalize(item)
+		}
+		return object
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case []interface{}:
+		for index, item := range v {
+			v[index] = normalize(item)
+		}
+		return v
+	}
+	return value
+}
+
+// Convert 在JSON、TOML、YAML之间转换配置文件内容
+func Convert(src string, data []byte, dst string) ([]byte, error) {
+	var conf Config
+	if err := Unmarshal(src, data, &conf); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %s", src, err.Error())
+	}
+	return Marshal(dst, conf)
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/util"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 环境变量前缀
+const EnvPrefix = "MOOC_"
+
+// 配置来源
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+	SourceMerged  = "merged"
+)
+
+// Origin 配置项来源, Detail为文件路径、环境变量名或命令行参数
+type Origin struct {
+	Source string `json:"source"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (o Origin) String() string {
+	if o.Detail == "" {
+		return o.Source
+	}
+	return o.Source + ": " + o.Detail
+}
+
+// Options 配置加载选项
+type Options struct {
+	// Path 配置文件路径, 为空时自动查找
+	Path string
+	// Flags 命令行覆盖的配置项, key为配置项路径, 如 global.limit
+	Flags map[string]string
+}
+
+// LoadOptions 命令行传入的加载选项
+var LoadOptions Options
+
+// Path 当前使用的配置文件路径
+var Path string
+
+// Sources 每个配置项的来源
+var Sources = map[string]Origin{}
+
+// Default 默认配置
+func Default() Config {
+	return Config{
+		Global: Global{
+			Server: "127.0.0.1:10086",
+			Limit:  3,
+		},
+		Users: []User{},
+	}
+}
+
+// UserConfigDir 用户级配置目录
+func UserConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, "mooc")
+}
+
+// searchDir 自动查找配置文件的目录
+type searchDir struct {
+	path string
+	name string
+}
+
+func searchDirs() []searchDir {
+	return []searchDir{{".", "当前目录"}, {UserConfigDir(), "用户配置目录"}}
+}
+
+// SearchPaths 自动查找配置文件时依次尝试的路径
+func SearchPaths() []string {
+	var paths []string
+	for _, dir := range searchDirs() {
+		for _, name := range FileNames {
+			paths = append(paths, filepath.Join(dir.path, name))
+		}
+	}
+	return paths
+}
+
+// ResolvePath 确定配置文件路径
+// 优先级: 命令行 --config > 环境变量 MOOC_CONFIG > 当前目录 > 用户配置目录
+// 目录中依次查找 config.json、config.toml、config.yaml、config.yml
+func ResolvePath(explicit string) (string, Origin) {
+	if explicit != "" {
+		return explicit, Origin{Source: SourceFlag, Detail: "--config"}
+	}
+	if env := os.Getenv(EnvPrefix + "CONFIG"); env != "" {
+		return env, Origin{Source: SourceEnv, Detail: EnvPrefix + "CONFIG"}
+	}
+	for _, dir := range searchDirs() {
+		for _, name := range FileNames {
+			path := filepath.Join(dir.path, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, Origin{Source: SourceDefault, Detail: dir.name}
+			}
+		}
+	}
+	return filepath.Join(UserConfigDir(), FileNames[0]), Origin{Source: SourceDefault, Detail: "用户配置目录"}
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行 的顺序加载配置
+func Load(opts Options) (Config, error) {
+	conf := Default()
+	sources := map[string]Origin{}
+
+	path, origin := ResolvePath(opts.Path)
+	sources["config"] = origin
+
+	// 主文件损坏时回退到备份
+	data, name, err := util.ReadFileWithBackup(path, util.DefaultBackups, func(data []byte) error {
+		return Valid(path, data)
+	})
+	if err != nil {
+		if origin.Source == SourceDefault && errors.Is(err, os.ErrNotExist) {
+			return conf, fmt.Errorf("没有找到配置文件, 已查找: %s", strings.Join(SearchPaths(), ", "))
+		}
+		return conf, errors.New("读取配置文件失败: " + err.Error())
+	}
+	if name != path {
+		origin.Detail += ", 配置文件损坏, 使用备份 " + name
+		sources["config"] = origin
+	}
+	err = Unmarshal(path, data, &conf)
+	if err != nil {
+		return conf, fmt.Errorf("解析配置文件 %s 失败: %s", path, err.Error())
+	}
+	raw := decodeRaw(path, data)
+
+	fields := globalFields(&conf)
+	for _, field := range fields {
+		if hasKey(raw, field.key) {
+			sources[field.key] = Origin{Source: SourceFile, Detail: path}
+		} else {
+			sources[field.key] = Origin{Source: SourceDefault}
+		}
+	}
+	sources["users"] = Origin{Source: SourceFile, Detail: path}
+
+	// 环境变量
+	for _, field := range fields {
+		for _, name := range field.envNames() {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := setValue(field.value, value); err != nil {
+				return conf, fmt.Errorf("环境变量 %s 无效: %s", name, err.Error())
+			}
+			sources[field.key] = Origin{Source: SourceEnv, Detail: name}
+			break
+		}
+	}
+
+	// 命令行
+	for key, value := range opts.Flags {
+		field, ok := findField(fields, key)
+		if !ok {
+			return conf, fmt.Errorf("未知配置项: %s", key)
+		}
+		if err := setValue(field.value, value); err != nil {
+			return conf, fmt.Errorf("参数 %s 无效: %s", key, err.Error())
+		}
+		sources[field.key] = Origin{Source: SourceFlag, Detail: key}
+	}
+
+	Path = path
+	Sources = sources
+	return conf, nil
+}
+
+// Setting 单个配置项的值和来源
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Origin Origin `json:"origin"`
+}
+
+// Describe 列出全部配置项及其来源, 密码不显示
+func Describe(conf Config) []Setting {
+	var settings []Setting
+	settings = append(settings, Setting{Key: "config", Value: Path, Origin: Sources["config"]})
+	// 未设置的运行参数显示默认值
+	effective := conf
+	effective.Global.Settings = DefaultSettings().Merge(conf.Global.Settings)
+	for _, field := range globalFields(&effective) {
+		settings = append(settings, Setting{
+			Key:    field.key,
+			Value:  fmt.Sprint(field.value.Interface()),
+			Origin: Sources[field.key],
+		})
+	}
+	for index, user := range conf.Users {
+		settings = append(settings, Setting{
+			Key:    fmt.Sprintf("users[%d]", index),
+			Value:  user.Key(),
+			Origin: Sources["users"],
+		})
+		settings = append(settings, Setting{
+			Key:    fmt.Sprintf("users[%d].settings", index),
+			Value:  conf.Effective(user).String(),
+			Origin: Origin{Source: SourceMerged, Detail: "默认值 < global.settings < users.settings"},
+		})
+	}
+	return settings
+}
+
+// Keys 可以通过环境变量和命令行覆盖的配置项
+func Keys() []string {
+	conf := Default()
+	var keys []string
+	for _, field := range globalFields(&conf) {
+		keys = append(keys, field.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+// envNames 环境变量名, 如 global.limit 对应 MOOC_GLOBAL_LIMIT, 也可以省略 GLOBAL 写作 MOOC_LIMIT
+func (f field) envNames() []string {
+	name := strings.ToUpper(strings.ReplaceAll(f.key, ".", "_"))
+	return []string{
+		EnvPrefix + name,
+		EnvPrefix + strings.TrimPrefix(name, "GLOBAL_"),
+	}
+}
+
+func findField(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key || f.key == "global."+key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func globalFields(conf *Config) []field {
+	return collectFields("global", reflect.ValueOf(&conf.Global).Elem())
+}
+
+func collectFields(prefix string, value reflect.Value) []field {
+	var fields []field
+	for index := 0; index < value.NumField(); index++ {
+		structField := value.Type().Field(index)
+		name := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "." + name
+		if structField.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(key, value.Field(index))...)
+			continue
+		}
+		fields = append(fields, field{key: key, value: value.Field(index)})
+	}
+	return fields
+}
+
+func hasKey(raw map[string]interface{}, key string) bool {
+	var current interface{} = raw
+	for _, part := range strings.Split(key, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current, ok = object[part]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func setValue(value reflect.Value, text string) error {
+	text = strings.TrimSpace(text)
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(number)
+	case reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(number)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Slice:
+		// 以逗号分隔, 每一项按元素类型解析
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			element := reflect.New(value.Type().Elem()).Elem()
+			if element.Kind() == reflect.Slice {
+				return fmt.Errorf("不支持的类型 %s", value.Type())
+			}
+			if err := setValue(element, item); err != nil {
+				return err
+			}
+			items = reflect.Append(items, element)
+		}
+		value.Set(items)
+	default:
+		return fmt.Errorf("不支持的类型 %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/util"
+)
+
+func TestLoadFallsBackToBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"global":{"limit":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(util.BackupName(path, 1), []byte(`{"global":{"limit":5},"users":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := Load(Options{Path: path})
+	if err != nil {
+		t.Fatalf("配置文件损坏时应使用备份: %v", err)
+	}
+	if conf.Global.Limit != 5 {
+		t.Fatalf("limit 为 %d, 应为备份中的5", conf.Global.Limit)
+	}
+	if origin := Sources["config"]; !strings.Contains(origin.Detail, util.BackupName(path, 1)) {
+		t.Fatalf("配置来源应说明使用了备份, 实际 %s", origin)
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(EnvPrefix+"CONFIG", "")
+
+	_, err = Load(Options{})
+	if err == nil {
+		t.Fatal("没有配置文件时应返回错误")
+	}
+	for _, path := range SearchPaths() {
+		if !strings.Contains(err.Error(), path) {
+			t.Fatalf("错误信息应列出查找过的 %s: %v", path, err)
+		}
+	}
+}
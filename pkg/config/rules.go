@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DateLayout 课程规则中日期的格式
+const DateLayout = "2006-01-02"
+
+// CourseRules 账号的选课规则, 不满足规则的课程不会加入任务队列
+type CourseRules struct {
+	// Include 设置了的条件都必须满足, 同一条件中满足任意一项即可
+	Include CourseMatch `json:"include,omitempty" toml:"include,omitempty" yaml:"include,omitempty"`
+	// Exclude 满足任意一项即排除
+	Exclude CourseMatch `json:"exclude,omitempty" toml:"exclude,omitempty" yaml:"exclude,omitempty"`
+	// 课程开始日期窗口, 格式 2006-01-02
+	StartAfter  string `json:"start_after,omitempty" toml:"start_after,omitempty" yaml:"start_after,omitempty"`
+	StartBefore string `json:"start_before,omitempty" toml:"start_before,omitempty" yaml:"start_before,omitempty"`
+	// 课程结束日期窗口, 格式 2006-01-02
+	EndAfter  string `json:"end_after,omitempty" toml:"end_after,omitempty" yaml:"end_after,omitempty"`
+	EndBefore string `json:"end_before,omitempty" toml:"end_before,omitempty" yaml:"end_before,omitempty"`
+	// Priorities 手动优先级, key为课程ID, 数值越大越先学习, 默认0
+	Priorities map[string]int `json:"priorities,omitempty" toml:"priorities,omitempty" yaml:"priorities,omitempty"`
+}
+
+// CourseMatch 课程匹配条件
+type CourseMatch struct {
+	// IDs 课程ID
+	IDs []int `json:"ids,omitempty" toml:"ids,omitempty" yaml:"ids,omitempty"`
+	// Names 课程名称正则表达式
+	Names []string `json:"names,omitempty" toml:"names,omitempty" yaml:"names,omitempty"`
+	// Categories 课程分类, 对应 categoryName
+	Categories []string `json:"categories,omitempty" toml:"categories,omitempty" yaml:"categories,omitempty"`
+	// Colleges 开课学院, 对应 collegeName
+	Colleges []string `json:"colleges,omitempty" toml:"colleges,omitempty" yaml:"colleges,omitempty"`
+}
+
+// Empty 是否未设置任何条件
+func (m CourseMatch) Empty() bool {
+	return len(m.IDs) == 0 && len(m.Names) == 0 && len(m.Categories) == 0 && len(m.Colleges) == 0
+}
+
+// Empty 是否未设置任何筛选条件
+func (r CourseRules) Empty() bool {
+	return r.Include.Empty() && r.Exclude.Empty() &&
+		r.StartAfter == "" && r.StartBefore == "" && r.EndAfter == "" && r.EndBefore == ""
+}
+
+// Validate 检查正则表达式和日期格式
+func (r CourseRules) Validate() []error {
+	var errs []error
+	for _, item := range []struct {
+		name  string
+		match CourseMatch
+	}{{"include", r.Include}, {"exclude", r.Exclude}} {
+		for _, pattern := range item.match.Names {
+			if _, err := regexp.Compile(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s.names 正则表达式 %q 错误: %s", item.name, pattern, err.Error()))
+			}
+		}
+	}
+	for _, item := range []struct {
+		name  string
+		value string
+	}{{"start_after", r.StartAfter}, {"start_before", r.StartBefore}, {"end_after", r.EndAfter}, {"end_before", r.EndBefore}} {
+		if item.value == "" {
+			continue
+		}
+		if _, err := time.ParseInLocation(DateLayout, item.value, time.Local); err != nil {
+			errs = append(errs, fmt.Errorf("%s 应为 %s 格式的日期, 当前为 %q", item.name, DateLayout, item.value))
+		}
+	}
+	for key := range r.Priorities {
+		if _, err := strconv.Atoi(key); err != nil {
+			errs = append(errs, fmt.Errorf("priorities 的key应为课程ID, 当前为 %q", key))
+		}
+	}
+	return errs
+}
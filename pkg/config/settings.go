@@ -0,0 +1,276 @@
+package config
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/window"
+	"strings"
+)
+
+// Settings 账号运行参数, 可以在 global 中统一设置, 也可以在 users 中单独覆盖
+// 数值为0或字符串为空表示沿用上一级的设置
+type Settings struct {
+	// Timeout HTTP请求超时, 秒
+	Timeout int `json:"timeout,omitempty" toml:"timeout,omitzero" yaml:"timeout,omitempty"`
+	// RetryCount 请求失败重试次数, -1 表示不重试
+	RetryCount int `json:"retry_count,omitempty" toml:"retry_count,omitzero" yaml:"retry_count,omitempty"`
+	// RetryWait 第一次重试前的等待时间, 之后每次翻倍, 秒
+	RetryWait int `json:"retry_wait,omitempty" toml:"retry_wait,omitzero" yaml:"retry_wait,omitempty"`
+	// RetryMaxWait 单次重试等待时间上限, 秒
+	RetryMaxWait int `json:"retry_max_wait,omitempty" toml:"retry_max_wait,omitzero" yaml:"retry_max_wait,omitempty"`
+	// RetryCodes 可以重试的接口 _code, 其他非0的 _code 不重试
+	RetryCodes []int `json:"retry_codes,omitempty" toml:"retry_codes,omitempty" yaml:"retry_codes,omitempty"`
+	// Proxy 代理地址, 如 http://127.0.0.1:7890、socks5://127.0.0.1:1080
+	Proxy string `json:"proxy,omitempty" toml:"proxy,omitempty" yaml:"proxy,omitempty"`
+	// CAFile 额外信任的CA证书文件, PEM格式, 用于自建证书或有HTTPS解密网关的网络
+	CAFile string `json:"ca_file,omitempty" toml:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// InsecureSkipVerify 不校验HTTPS证书, 只在学校平台证书无效时使用, 任意一级设置为 true 即生效
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" toml:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+	// ConnectTimeout 建立连接和TLS握手超时, 秒
+	ConnectTimeout int `json:"connect_timeout,omitempty" toml:"connect_timeout,omitzero" yaml:"connect_timeout,omitempty"`
+	// ReadTimeout 发出请求后等待响应头的超时, 秒
+	ReadTimeout int `json:"read_timeout,omitempty" toml:"read_timeout,omitzero" yaml:"read_timeout,omitempty"`
+	// UserAgent 为空时随机生成手机浏览器UA
+	UserAgent string `json:"user_agent,omitempty" toml:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	// MaxCourses 单个账号同时学习的课程数, 0 表示不限制
+	MaxCourses int `json:"max_courses,omitempty" toml:"max_courses,omitzero" yaml:"max_courses,omitempty"`
+	// PollInterval 学习进度轮询间隔, 秒
+	PollInterval int `json:"poll_interval,omitempty" toml:"poll_interval,omitzero" yaml:"poll_interval,omitempty"`
+	// RateLimit 每个平台域名每秒最多请求数, 同一域名的账号共用, 0 表示不限制
+	RateLimit float64 `json:"rate_limit,omitempty" toml:"rate_limit,omitzero" yaml:"rate_limit,omitempty"`
+	// BreakerThreshold 同一平台域名连续请求失败多少次后暂停该域名的全部任务, -1 表示不暂停
+	BreakerThreshold int `json:"breaker_threshold,omitempty" toml:"breaker_threshold,omitzero" yaml:"breaker_threshold,omitempty"`
+	// BreakerInterval 暂停期间探测服务器是否恢复的间隔, 秒
+	BreakerInterval int `json:"breaker_interval,omitempty" toml:"breaker_interval,omitzero" yaml:"breaker_interval,omitempty"`
+	// StallPolls 连续多少次查询进度没有增长视为卡住, -1 表示不按次数检测
+	StallPolls int `json:"stall_polls,omitempty" toml:"stall_polls,omitzero" yaml:"stall_polls,omitempty"`
+	// StallMinutes 进度多少分钟没有增长视为卡住, -1 表示不按时间检测
+	StallMinutes int `json:"stall_minutes,omitempty" toml:"stall_minutes,omitzero" yaml:"stall_minutes,omitempty"`
+	// StallAction 进度卡住后的处理: restart 重新开始学习记录, relogin 重新登录, fail 放弃当前节点
+	StallAction string `json:"stall_action,omitempty" toml:"stall_action,omitempty" yaml:"stall_action,omitempty"`
+	// Captcha 验证码识别方式: manual 手动输入, remote 上传到 captcha_url 识别
+	Captcha string `json:"captcha,omitempty" toml:"captcha,omitempty" yaml:"captcha,omitempty"`
+	// CaptchaURL 第三方验证码识别服务地址, 只在 captcha 为 remote 时使用
+	CaptchaURL string `json:"captcha_url,omitempty" toml:"captcha_url,omitempty" yaml:"captcha_url,omitempty"`
+	// CaptchaTimeout 等待手动输入验证码的时间, 秒
+	CaptchaTimeout int `json:"captcha_timeout,omitempty" toml:"captcha_timeout,omitzero" yaml:"captcha_timeout,omitempty"`
+	// HistoryInterval 进度没有变化时保存快照的间隔, 分钟, -1 表示不保存进度历史
+	HistoryInterval int `json:"history_interval,omitempty" toml:"history_interval,omitzero" yaml:"history_interval,omitempty"`
+	// HistoryDays 进度历史保留的天数, -1 表示一直保留, 只读取 global.settings 中的值
+	HistoryDays int `json:"history_days,omitempty" toml:"history_days,omitzero" yaml:"history_days,omitempty"`
+	// Windows 允许学习的时段, 如 "mon-fri 08:00-23:00", 为空表示任何时间都可以学习
+	Windows []string `json:"windows,omitempty" toml:"windows,omitempty" yaml:"windows,omitempty"`
+}
+
+// 进度卡住后的处理方式
+const (
+	StallRestart = "restart"
+	StallRelogin = "relogin"
+	StallFail    = "fail"
+)
+
+// 验证码识别方式
+const (
+	CaptchaManual = "manual"
+	CaptchaRemote = "remote"
+)
+
+// DefaultSettings 内置默认值
+func DefaultSettings() Settings {
+	return Settings{
+		Timeout:          30,
+		ConnectTimeout:   10,
+		ReadTimeout:      30,
+		RetryCount:       3,
+		RetryWait:        1,
+		RetryMaxWait:     30,
+		BreakerThreshold: 5,
+		BreakerInterval:  30,
+		PollInterval:     10,
+		StallPolls:       30,
+		StallMinutes:     10,
+		StallAction:      StallRestart,
+		Captcha:          CaptchaManual,
+		CaptchaTimeout:   300,
+		HistoryInterval:  60,
+		HistoryDays:      30,
+	}
+}
+
+// Merge 用 override 中设置了的字段覆盖当前设置
+func (s Settings) Merge(override Settings) Settings {
+	if override.Timeout != 0 {
+		s.Timeout = override.Timeout
+	}
+	if override.RetryCount != 0 {
+		s.RetryCount = override.RetryCount
+	}
+	if override.RetryWait != 0 {
+		s.RetryWait = override.RetryWait
+	}
+	if override.RetryMaxWait != 0 {
+		s.RetryMaxWait = override.RetryMaxWait
+	}
+	if len(override.RetryCodes) != 0 {
+		s.RetryCodes = override.RetryCodes
+	}
+	if override.Proxy != "" {
+		s.Proxy = override.Proxy
+	}
+	if override.CAFile != "" {
+		s.CAFile = override.CAFile
+	}
+	if override.InsecureSkipVerify {
+		s.InsecureSkipVerify = true
+	}
+	if override.ConnectTimeout != 0 {
+		s.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.ReadTimeout != 0 {
+		s.ReadTimeout = override.ReadTimeout
+	}
+	if override.UserAgent != "" {
+		s.UserAgent = override.UserAgent
+	}
+	if override.MaxCourses != 0 {
+		s.MaxCourses = override.MaxCourses
+	}
+	if override.PollInterval != 0 {
+		s.PollInterval = override.PollInterval
+	}
+	if override.RateLimit != 0 {
+		s.RateLimit = override.RateLimit
+	}
+	if override.BreakerThreshold != 0 {
+		s.BreakerThreshold = override.BreakerThreshold
+	}
+	if override.BreakerInterval != 0 {
+		s.BreakerInterval = override.BreakerInterval
+	}
+	if override.StallPolls != 0 {
+		s.StallPolls = override.StallPolls
+	}
+	if override.StallMinutes != 0 {
+		s.StallMinutes = override.StallMinutes
+	}
+	if override.StallAction != "" {
+		s.StallAction = override.StallAction
+	}
+	if override.Captcha != "" {
+		s.Captcha = override.Captcha
+	}
+	if override.CaptchaURL != "" {
+		s.CaptchaURL = override.CaptchaURL
+	}
+	if override.CaptchaTimeout != 0 {
+		s.CaptchaTimeout = override.CaptchaTimeout
+	}
+	if override.HistoryInterval != 0 {
+		s.HistoryInterval = override.HistoryInterval
+	}
+	if override.HistoryDays != 0 {
+		s.HistoryDays = override.HistoryDays
+	}
+	if len(override.Windows) != 0 {
+		s.Windows = override.Windows
+	}
+	return s
+}
+
+// Retries 实际重试次数
+func (s Settings) Retries() int {
+	if s.RetryCount < 0 {
+		return 0
+	}
+	return s.RetryCount
+}
+
+func (s Settings) String() string {
+	proxy := s.Proxy
+	if proxy == "" {
+		proxy = "无"
+	}
+	maxCourses := "不限"
+	if s.MaxCourses > 0 {
+		maxCourses = fmt.Sprint(s.MaxCourses)
+	}
+	rateLimit := "不限"
+	if s.RateLimit > 0 {
+		rateLimit = fmt.Sprintf("%g次/秒", s.RateLimit)
+	}
+	certificate := "系统"
+	if s.CAFile != "" {
+		certificate = "系统+" + s.CAFile
+	}
+	if s.InsecureSkipVerify {
+		certificate = "不校验"
+	}
+	userAgent := s.UserAgent
+	if userAgent == "" {
+		userAgent = "随机"
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("超时 %ds(连接 %ds, 响应 %ds)", s.Timeout, s.ConnectTimeout, s.ReadTimeout),
+		fmt.Sprintf("重试 %d次/%d~%ds", s.Retries(), s.RetryWait, s.RetryMaxWait),
+		fmt.Sprintf("代理 %s", proxy),
+		fmt.Sprintf("证书 %s", certificate),
+		fmt.Sprintf("UA %s", userAgent),
+		fmt.Sprintf("账号并发 %s", maxCourses),
+		fmt.Sprintf("轮询 %ds", s.PollInterval),
+		fmt.Sprintf("限速 %s", rateLimit),
+		fmt.Sprintf("卡住检测 %s", s.describeStall()),
+		fmt.Sprintf("验证码 %s", s.describeCaptcha()),
+		fmt.Sprintf("进度历史 %s", s.describeHistory()),
+		fmt.Sprintf("学习时段 %s", s.Schedule()),
+	}, ", ")
+}
+
+func (s Settings) describeStall() string {
+	var rules []string
+	if s.StallPolls > 0 {
+		rules = append(rules, fmt.Sprintf("%d次", s.StallPolls))
+	}
+	if s.StallMinutes > 0 {
+		rules = append(rules, fmt.Sprintf("%d分钟", s.StallMinutes))
+	}
+	if len(rules) == 0 {
+		return "关闭"
+	}
+	return strings.Join(rules, "或") + "/" + s.StallAction
+}
+
+func (s Settings) describeCaptcha() string {
+	if s.Captcha == CaptchaRemote {
+		return "上传到 " + s.CaptchaURL
+	}
+	return fmt.Sprintf("手动输入/%ds", s.CaptchaTimeout)
+}
+
+func (s Settings) describeHistory() string {
+	if s.HistoryInterval < 0 {
+		return "关闭"
+	}
+	days := "永久"
+	if s.HistoryDays > 0 {
+		days = fmt.Sprintf("%d天", s.HistoryDays)
+	}
+	return fmt.Sprintf("%d分钟/保留%s", s.HistoryInterval, days)
+}
+
+// Schedule 解析后的学习时段, 格式错误的时段在 Validate 中报告, 这里忽略
+func (s Settings) Schedule() window.Windows {
+	windows, err := window.ParseAll(s.Windows)
+	if err != nil {
+		return nil
+	}
+	return windows
+}
+
+// Effective 账号最终生效的设置: 默认值 < global.settings < users[].settings
+func (c Config) Effective(user User) Settings {
+	return DefaultSettings().Merge(c.Global.Settings).Merge(user.Settings)
+}
+
+// EffectiveSettings 按当前加载的配置计算账号生效的设置
+func EffectiveSettings(user User) Settings {
+	return Conf.Effective(user)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/window"
+	"net"
+	"net/url"
+	"os"
+)
+
+// Validate 检查配置, 返回发现的全部问题
+func (c Config) Validate() []error {
+	var errs []error
+	addf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Global.Limit <= 0 {
+		addf("global.limit 必须大于0, 当前为 %d", c.Global.Limit)
+	}
+	if c.Global.Server != "" {
+		if _, _, err := net.SplitHostPort(c.Global.Server); err != nil {
+			addf("global.server 格式错误, 应为 host:port 或 :port: %s", err.Error())
+		}
+	}
+	for _, err := range c.Global.Settings.Validate() {
+		addf("global.settings: %s", err.Error())
+	}
+
+	if len(c.Users) == 0 {
+		addf("users 为空, 至少需要配置一个账号")
+	}
+	keys := map[string]int{}
+	for index, user := range c.Users {
+		name := fmt.Sprintf("users[%d]", index)
+		if user.Username == "" {
+			addf("%s.username 不能为空", name)
+		}
+		if user.Password == "" {
+			addf("%s.password 不能为空", name)
+		}
+		if parse, err := url.Parse(user.BaseURL); err != nil || parse.Host == "" || (parse.Scheme != "http" && parse.Scheme != "https") {
+			addf("%s.base_url 应为 http(s)://域名 的形式, 当前为 %q", name, user.BaseURL)
+		} else if parse.Path != "" && parse.Path != "/" {
+			addf("%s.base_url 不要带路径, 当前为 %q", name, user.BaseURL)
+		}
+		if user.SchoolID < 0 {
+			addf("%s.school_id 不能为负数", name)
+		}
+		if previous, ok := keys[user.Key()]; ok {
+			addf("%s 与 users[%d] 是同一个账号 %s", name, previous, user.Key())
+		} else {
+			keys[user.Key()] = index
+		}
+		for _, err := range user.Settings.Validate() {
+			addf("%s.settings: %s", name, err.Error())
+		}
+		for _, err := range user.Courses.Validate() {
+			addf("%s.courses.%s", name, err.Error())
+		}
+	}
+	return errs
+}
+
+// Validate 检查运行参数
+func (s Settings) Validate() []error {
+	var errs []error
+	if s.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout 不能为负数"))
+	}
+	if s.ConnectTimeout < 0 {
+		errs = append(errs, fmt.Errorf("connect_timeout 不能为负数"))
+	}
+	if s.ReadTimeout < 0 {
+		errs = append(errs, fmt.Errorf("read_timeout 不能为负数"))
+	}
+	if s.CAFile != "" {
+		if data, err := os.ReadFile(s.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("ca_file 无法读取: %s", err.Error()))
+		} else if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			errs = append(errs, fmt.Errorf("ca_file %s 中没有有效的PEM证书", s.CAFile))
+		}
+	}
+	if s.RetryCount < -1 {
+		errs = append(errs, fmt.Errorf("retry_count 最小为-1"))
+	}
+	if s.RetryWait < 0 {
+		errs = append(errs, fmt.Errorf("retry_wait 不能为负数"))
+	}
+	if s.RetryMaxWait < 0 {
+		errs = append(errs, fmt.Errorf("retry_max_wait 不能为负数"))
+	}
+	if s.MaxCourses < 0 {
+		errs = append(errs, fmt.Errorf("max_courses 不能为负数"))
+	}
+	if s.BreakerThreshold < -1 {
+		errs = append(errs, fmt.Errorf("breaker_threshold 最小为-1"))
+	}
+	if s.BreakerInterval < 0 {
+		errs = append(errs, fmt.Errorf("breaker_interval 不能为负数"))
+	}
+	if s.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit 不能为负数"))
+	}
+	if s.PollInterval < 0 {
+		errs = append(errs, fmt.Errorf("poll_interval 不能为负数"))
+	}
+	if s.StallPolls < -1 {
+		errs = append(errs, fmt.Errorf("stall_polls 最小为-1"))
+	}
+	if s.StallMinutes < -1 {
+		errs = append(errs, fmt.Errorf("stall_minutes 最小为-1"))
+	}
+	switch s.StallAction {
+	case "", StallRestart, StallRelogin, StallFail:
+	default:
+		errs = append(errs, fmt.Errorf("stall_action 只能是 %s、%s、%s, 当前为 %q", StallRestart, StallRelogin, StallFail, s.StallAction))
+	}
+	switch s.Captcha {
+	case "", CaptchaManual:
+	case CaptchaRemote:
+		if parse, err := url.Parse(s.CaptchaURL); err != nil || parse.Host == "" || (parse.Scheme != "http" && parse.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("captcha 为 remote 时 captcha_url 应为 http(s) 地址, 当前为 %q", s.CaptchaURL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("captcha 只能是 %s、%s, 当前为 %q", CaptchaManual, CaptchaRemote, s.Captcha))
+	}
+	if s.CaptchaTimeout < 0 {
+		errs = append(errs, fmt.Errorf("captcha_timeout 不能为负数"))
+	}
+	if s.HistoryInterval < -1 {
+		errs = append(errs, fmt.Errorf("history_interval 最小为-1"))
+	}
+	if s.HistoryDays < -1 {
+		errs = append(errs, fmt.Errorf("history_days 最小为-1"))
+	}
+	if _, err := window.ParseAll(s.Windows); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Proxy != "" {
+		parse, err := url.Parse(s.Proxy)
+		if err != nil || parse.Host == "" {
+			errs = append(errs, fmt.Errorf("proxy 格式错误: %q", s.Proxy))
+		} else if parse.Scheme != "http" && parse.Scheme != "https" && parse.Scheme != "socks5" {
+			errs = append(errs, fmt.Errorf("proxy 只支持 http、https、socks5, 当前为 %s", parse.Scheme))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,283 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Node 节点进度
+type Node struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Chapter    string `json:"chapter"`
+	VideoState int    `json:"video_state"`
+	// Studied 已学习时长, 秒
+	Studied int `json:"studied"`
+}
+
+// Snapshot 某一时刻的课程进度
+type Snapshot struct {
+	Time         time.Time `json:"time"`
+	User         string    `json:"user"`
+	CourseID     int       `json:"course_id"`
+	Course       string    `json:"course"`
+	Progress     float32   `json:"progress"`
+	VideoLearned int       `json:"video_learned"`
+	VideoCount   int       `json:"video_count"`
+	Score        float32   `json:"score"`
+	Rank         int       `json:"rank"`
+	Nodes        []Node    `json:"nodes,omitempty"`
+}
+
+// NewSnapshot 从平台返回的课程生成快照, 不包括节点
+func NewSnapshot(user string, course types.CoursesList) Snapshot {
+	return Snapshot{
+		Time:         time.Now(),
+		User:         user,
+		CourseID:     course.ID,
+		Course:       course.Name,
+		Progress:     course.Progress,
+		VideoLearned: course.VideoLearned,
+		VideoCount:   course.VideoCount,
+		Score:        course.ResultScore,
+		Rank:         course.ResultRank,
+	}
+}
+
+// NewNodes 从章节生成节点进度
+func NewNodes(chapters []types.ChaptersList) []Node {
+	var nodes []Node
+	for _, chapter := range chapters {
+		for _, node := range chapter.NodeList {
+			nodes = append(nodes, NewNode(chapter.Name, node))
+		}
+	}
+	return nodes
+}
+
+// NewNode 生成单个节点的进度
+func NewNode(chapter string, node types.ChaptersNodeList) Node {
+	return Node{
+		ID:         node.ID,
+		Name:       node.Name,
+		Chapter:    chapter,
+		VideoState: node.VideoState,
+		Studied:    node.StudiedSeconds(),
+	}
+}
+
+// bucket 存放快照的顶层bucket, 每门课程一个子bucket, 快照以时间为key
+var bucket = []byte("snapshots")
+
+// 进度历史数据库, 第一次使用时打开
+var store = struct {
+	sync.Mutex
+	db *bolt.DB
+}{}
+
+// Path 进度历史数据库文件
+func Path() string {
+	return filepath.Join(config.DataDir, "history.db")
+}
+
+// open 打开数据库, 同一时间只能有一个进程打开
+func open() (*bolt.DB, error) {
+	store.Lock()
+	defer store.Unlock()
+	if store.db != nil {
+		return store.db, nil
+	}
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(Path(), 0644, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("进度历史数据库 %s 正在被其他进程使用, 运行中请通过web端查询", Path())
+	}
+	if err != nil {
+		return nil, err
+	}
+	store.db = db
+	return db, nil
+}
+
+// Close 关闭数据库
+func Close() error {
+	store.Lock()
+	defer store.Unlock()
+	if store.db == nil {
+		return nil
+	}
+	err := store.db.Close()
+	store.db = nil
+	return err
+}
+
+func courseKey(user string, courseID int) []byte {
+	return []byte(fmt.Sprintf("%s#%d", user, courseID))
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// last 课程最新的一次快照
+func last(course *bolt.Bucket) (Snapshot, bool, error) {
+	var snapshot Snapshot
+	if course == nil {
+		return snapshot, false, nil
+	}
+	_, value := course.Cursor().Last()
+	if value == nil {
+		return snapshot, false, nil
+	}
+	return snapshot, true, json.Unmarshal(value, &snapshot)
+}
+
+// Record 保存课程快照, 快照中没有的节点沿用上一次的状态
+// 与上一次相比没有变化并且间隔不足 interval 时不保存, 返回是否保存
+func Record(snapshot Snapshot, interval time.Duration) (bool, error) {
+	return update(snapshot.User, snapshot.CourseID, interval, func(previous Snapshot, ok bool) (Snapshot, bool) {
+		if ok {
+			snapshot.Nodes = mergeNodes(previous.Nodes, snapshot.Nodes)
+		}
+		return snapshot, true
+	})
+}
+
+// RecordNodes 只更新节点进度, 课程进度沿用上一次的快照, 课程还没有快照时不保存
+func RecordNodes(user string, courseID int, nodes []Node, interval time.Duration) (bool, error) {
+	return update(user, courseID, interval, func(previous Snapshot, ok bool) (Snapshot, bool) {
+		if !ok {
+			return previous, false
+		}
+		snapshot := previous
+		snapshot.Time = time.Now()
+		snapshot.Nodes = mergeNodes(previous.Nodes, nodes)
+		return snapshot, true
+	})
+}
+
+func update(user string, courseID int, interval time.Duration, build func(previous Snapshot, ok bool) (Snapshot, bool)) (bool, error) {
+	db, err := open()
+	if err != nil {
+		return false, err
+	}
+	saved := false
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		course, err := root.CreateBucketIfNotExists(courseKey(user, courseID))
+		if err != nil {
+			return err
+		}
+		previous, ok, err := last(course)
+		if err != nil {
+			return err
+		}
+		snapshot, save := build(previous, ok)
+		if !save {
+			return nil
+		}
+		if ok && snapshot.Time.Sub(previous.Time) < interval && !changed(previous, snapshot) {
+			return nil
+		}
+		value, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+		saved = true
+		return course.Put(timeKey(snapshot.Time), value)
+	})
+	return saved, err
+}
+
+// mergeNodes 用 updates 覆盖 nodes 中相同ID的节点, 保持原有顺序
+func mergeNodes(nodes []Node, updates []Node) []Node {
+	index := map[int]int{}
+	merged := append([]Node(nil), nodes...)
+	for i, node := range merged {
+		index[node.ID] = i
+	}
+	for _, node := range updates {
+		if i, ok := index[node.ID]; ok {
+			// 只知道节点状态时保留原来的章节名称
+			if node.Chapter == "" {
+				node.Chapter = merged[i].Chapter
+			}
+			merged[i] = node
+			continue
+		}
+		index[node.ID] = len(merged)
+		merged = append(merged, node)
+	}
+	return merged
+}
+
+// changed 两次快照的进度、成绩、排名或节点状态是否不同, 节点学习时长的变化只按间隔保存
+func changed(a, b Snapshot) bool {
+	if a.Progress != b.Progress || a.VideoLearned != b.VideoLearned || a.VideoCount != b.VideoCount ||
+		a.Score != b.Score || a.Rank != b.Rank || len(a.Nodes) != len(b.Nodes) {
+		return true
+	}
+	for i := range a.Nodes {
+		if a.Nodes[i].VideoState != b.Nodes[i].VideoState {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune 删除 days 天之前的快照, 每门课程至少保留最新的一次, 返回删除的数量
+func Prune(days int) (int, error) {
+	if days <= 0 {
+		return 0, nil
+	}
+	db, err := open()
+	if err != nil {
+		return 0, err
+	}
+	deadline := timeKey(time.Now().AddDate(0, 0, -days))
+	removed := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(name, _ []byte) error {
+			course := root.Bucket(name)
+			if course == nil {
+				return nil
+			}
+			latest, _ := course.Cursor().Last()
+			// 游标删除后继续遍历会漏掉节点, 先收集再删除
+			var expired [][]byte
+			cursor := course.Cursor()
+			for key, _ := cursor.First(); key != nil && bytes.Compare(key, deadline) < 0 && !bytes.Equal(key, latest); key, _ = cursor.Next() {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			for _, key := range expired {
+				if err := course.Delete(key); err != nil {
+					return err
+				}
+			}
+			removed += len(expired)
+			return nil
+		})
+	})
+	return removed, err
+}
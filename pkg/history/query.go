@@ -0,0 +1,151 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// NodeChange 节点状态的变化
+type NodeChange struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Chapter string `json:"chapter"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+}
+
+// Change 课程从 From 到 To 之间的变化
+type Change struct {
+	User     string `json:"user"`
+	CourseID int    `json:"course_id"`
+	Course   string `json:"course"`
+	// New 在起始时间之前没有快照, 从第一次快照开始比较
+	New              bool         `json:"new"`
+	From             time.Time    `json:"from"`
+	To               time.Time    `json:"to"`
+	ProgressFrom     float32      `json:"progress_from"`
+	ProgressTo       float32      `json:"progress_to"`
+	VideoLearnedFrom int          `json:"video_learned_from"`
+	VideoLearnedTo   int          `json:"video_learned_to"`
+	ScoreFrom        float32      `json:"score_from"`
+	ScoreTo          float32      `json:"score_to"`
+	RankFrom         int          `json:"rank_from"`
+	RankTo           int          `json:"rank_to"`
+	Nodes            []NodeChange `json:"nodes,omitempty"`
+}
+
+// Course 课程从 since 开始的全部快照, 按时间排序
+func Course(user string, courseID int, since time.Time) ([]Snapshot, error) {
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucket)
+		if root == nil {
+			return nil
+		}
+		course := root.Bucket(courseKey(user, courseID))
+		if course == nil {
+			return nil
+		}
+		cursor := course.Cursor()
+		for key, value := cursor.Seek(timeKey(since)); key != nil; key, value = cursor.Next() {
+			var snapshot Snapshot
+			if err := json.Unmarshal(value, &snapshot); err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	return snapshots, err
+}
+
+// Changes 每门课程从 since 到最新快照之间的变化, 没有变化的课程不返回
+func Changes(since time.Time) ([]Change, error) {
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(name, _ []byte) error {
+			course := root.Bucket(name)
+			if course == nil {
+				return nil
+			}
+			cursor := course.Cursor()
+			_, value := cursor.Last()
+			if value == nil {
+				return nil
+			}
+			var latest Snapshot
+			if err := json.Unmarshal(value, &latest); err != nil {
+				return err
+			}
+			if !latest.Time.After(since) {
+				return nil
+			}
+			// 起始时间之前的最后一次快照作为基准, 没有时用之后的第一次
+			// 最新快照晚于 since, Seek 一定能找到
+			isNew := false
+			key, value := cursor.Seek(timeKey(since))
+			if !bytes.Equal(key, timeKey(since)) {
+				key, value = cursor.Prev()
+			}
+			if key == nil {
+				isNew = true
+				_, value = cursor.First()
+			}
+			var base Snapshot
+			if err := json.Unmarshal(value, &base); err != nil {
+				return err
+			}
+			if change, ok := compare(base, latest, isNew); ok {
+				changes = append(changes, change)
+			}
+			return nil
+		})
+	})
+	return changes, err
+}
+
+// compare 比较两次快照, 没有变化并且不是新课程时返回 false
+func compare(from, to Snapshot, isNew bool) (Change, bool) {
+	change := Change{
+		User:             to.User,
+		CourseID:         to.CourseID,
+		Course:           to.Course,
+		New:              isNew,
+		From:             from.Time,
+		To:               to.Time,
+		ProgressFrom:     from.Progress,
+		ProgressTo:       to.Progress,
+		VideoLearnedFrom: from.VideoLearned,
+		VideoLearnedTo:   to.VideoLearned,
+		ScoreFrom:        from.Score,
+		ScoreTo:          to.Score,
+		RankFrom:         from.Rank,
+		RankTo:           to.Rank,
+	}
+	states := map[int]int{}
+	for _, node := range from.Nodes {
+		states[node.ID] = node.VideoState
+	}
+	for _, node := range to.Nodes {
+		if state, ok := states[node.ID]; !ok || state != node.VideoState {
+			change.Nodes = append(change.Nodes, NodeChange{ID: node.ID, Name: node.Name, Chapter: node.Chapter, From: state, To: node.VideoState})
+		}
+	}
+	ok := isNew || len(change.Nodes) > 0 || from.Progress != to.Progress || from.VideoLearned != to.VideoLearned ||
+		from.Score != to.Score || from.Rank != to.Rank
+	return change, ok
+}
@@ -0,0 +1,165 @@
+package plan
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/task"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/aoaostar/mooc/pkg/yinghua"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"time"
+)
+
+// 节点类型
+const (
+	KindVideo = todo.KindVideo
+	KindWork  = todo.KindWork
+	KindExam  = todo.KindExam
+	KindVote  = todo.KindVote
+	KindFile  = todo.KindFile
+	KindOther = todo.KindOther
+)
+
+// Node 节点学习计划
+type Node struct {
+	ChapterID   int    `json:"chapter_id"`
+	Chapter     string `json:"chapter"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Locked      bool   `json:"locked"`
+	UnlockTime  string `json:"unlock_time,omitempty"`
+	VideoState  int    `json:"video_state"`
+	Duration    int    `json:"duration"`
+	Studied     int    `json:"studied"`
+	Pending     bool   `json:"pending"`
+	Remaining   int    `json:"remaining"`
+	Description string `json:"description,omitempty"`
+}
+
+// Course 课程学习计划
+type Course struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Progress      float32 `json:"progress"`
+	EndDate       string  `json:"end_date"`
+	Skipped       string  `json:"skipped,omitempty"`
+	Excluded      string  `json:"excluded,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	Nodes         []Node  `json:"nodes,omitempty"`
+	PendingVideos int     `json:"pending_videos"`
+	LockedVideos  int     `json:"locked_videos"`
+	OtherNodes    int     `json:"other_nodes"`
+	Remaining     int     `json:"remaining"`
+}
+
+// User 账号学习计划
+type User struct {
+	User      string   `json:"user"`
+	Error     string   `json:"error,omitempty"`
+	Courses   []Course `json:"courses"`
+	Remaining int      `json:"remaining"`
+}
+
+// Kind 节点类型
+func Kind(node types.ChaptersNodeList) string {
+	return todo.Kind(node)
+}
+
+// Locked 节点是否处于锁定状态
+func Locked(node types.ChaptersNodeList, now time.Time) bool {
+	return node.NodeLock != 0 || !yinghua.UnlockAt(node, now).IsZero()
+}
+
+// Build 登录全部账号, 获取课程和章节, 生成学习计划, 不会提交任何学习记录
+func Build(users []config.User) []User {
+	var plans []User
+	for _, user := range users {
+		plans = append(plans, BuildUser(user))
+	}
+	return plans
+}
+
+// BuildUser 生成单个账号的学习计划
+func BuildUser(user config.User) User {
+	result := User{User: user.Key()}
+	instance := yinghua.New(user)
+	err := instance.Login()
+	if err != nil {
+		result.Error = "登录失败: " + err.Error()
+		return result
+	}
+	err = instance.GetCourses()
+	if err != nil {
+		result.Error = "获取课程失败: " + err.Error()
+		return result
+	}
+	for _, course := range instance.Courses {
+		plan := BuildCourse(instance, course)
+		result.Remaining += plan.Remaining
+		result.Courses = append(result.Courses, plan)
+	}
+	return result
+}
+
+// BuildCourse 生成课程学习计划
+func BuildCourse(instance *yinghua.YingHua, course types.CoursesList) Course {
+	result := Course{
+		ID:       course.ID,
+		Name:     course.Name,
+		Progress: course.Progress,
+		EndDate:  course.EndDate,
+	}
+	if reason := task.SkipReason(course); reason != "" {
+		result.Skipped = reason
+		return result
+	}
+	if reason := task.ExcludeReason(instance.User, course); reason != "" {
+		result.Excluded = reason
+		return result
+	}
+	chapters, err := instance.GetChapters(course)
+	if err != nil {
+		result.Error = "获取章节失败: " + err.Error()
+		return result
+	}
+	now := time.Now()
+	for _, chapter := range chapters {
+		for _, item := range chapter.NodeList {
+			node := Node{
+				ChapterID:  chapter.ID,
+				Chapter:    chapter.Name,
+				ID:         item.ID,
+				Name:       item.Name,
+				Kind:       Kind(item),
+				Locked:     Locked(item, now),
+				UnlockTime: item.UnlockTime,
+				VideoState: item.VideoState,
+				Duration:   item.VideoSeconds(),
+				Studied:    item.StudiedSeconds(),
+			}
+			switch {
+			case node.Kind != KindVideo:
+				node.Description = "非视频, 需要手动完成"
+				result.OtherNodes++
+			case node.VideoState == 2:
+				node.Description = "已完成"
+			case node.Locked:
+				node.Pending = true
+				node.Description = "未解锁"
+				if until := yinghua.UnlockAt(item, now); !until.IsZero() {
+					node.Description = until.Format("2006-01-02 15:04") + " 解锁"
+				}
+				result.LockedVideos++
+			default:
+				node.Pending = true
+				result.PendingVideos++
+			}
+			if node.Pending {
+				node.Remaining = item.RemainingSeconds()
+				result.Remaining += node.Remaining
+			}
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package plan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/config"
+)
+
+// courses 模拟平台的课程列表: 已完成、被规则排除、需要学习的课程各一门
+var courses = []map[string]interface{}{
+	{"id": 1, "name": "已学完的课程", "state": 1, "progress": 1, "endDate": "2099-01-01"},
+	{"id": 2, "name": "体育", "state": 1, "progress": 0, "endDate": "2099-01-01"},
+	{"id": 3, "name": "大学英语", "state": 1, "progress": 0.25, "endDate": "2099-01-01"},
+}
+
+func fakePlatform(unlock time.Time) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		_ = request.ParseForm()
+		body := map[string]interface{}{"_code": 0, "msg": "ok"}
+		switch request.URL.Path {
+		case "/api/login.json":
+			body["result"] = map[string]interface{}{"data": map[string]interface{}{"token": "token"}}
+		case "/api/course.json":
+			body["result"] = map[string]interface{}{"list": courses}
+		case "/api/course/chapter.json":
+			courseID, _ := strconv.Atoi(request.FormValue("courseId"))
+			if courseID != 3 {
+				http.Error(writer, "不应获取该课程的章节", http.StatusBadRequest)
+				return
+			}
+			body["result"] = map[string]interface{}{"list": []map[string]interface{}{{"id": 30, "name": "第一章", "nodeList": []map[string]interface{}{
+				{"id": 31, "name": "已学完", "tabVideo": true, "videoDuration": "00:10:00", "videoState": 2},
+				{"id": 32, "name": "学了一半", "tabVideo": true, "videoDuration": "00:10:00", "duration": "00:04:00"},
+				{"id": 33, "name": "按时间解锁", "tabVideo": true, "videoDuration": "00:05:00", "unlockTimeStamp": unlock.Unix()},
+				{"id": 34, "name": "前面的节点没学完", "tabVideo": true, "videoDuration": "00:02:00", "nodeLock": 1},
+				{"id": 35, "name": "作业", "tabWork": true},
+			}}}}
+		default:
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(body)
+	}
+}
+
+func TestBuildUser(t *testing.T) {
+	unlock := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+	server := httptest.NewServer(fakePlatform(unlock))
+	defer server.Close()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Conf
+	defer func() {
+		config.Conf = old
+		_ = os.Chdir(wd)
+	}()
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{BreakerThreshold: -1, RetryCount: -1}}}
+	user := config.User{Username: "tester", Password: "pw", BaseURL: server.URL}
+	user.Courses.Exclude.Names = []string{"^体育$"}
+
+	result := BuildUser(user)
+	if result.Error != "" || len(result.Courses) != 3 {
+		t.Fatalf("应列出全部3门课程, 得到 %+v", result)
+	}
+	if result.Courses[0].Skipped == "" {
+		t.Fatal("已学完的课程应标记为跳过")
+	}
+	if result.Courses[1].Excluded == "" {
+		t.Fatal("被选课规则排除的课程应标记为排除")
+	}
+
+	course := result.Courses[2]
+	if course.PendingVideos != 1 || course.LockedVideos != 2 || course.OtherNodes != 1 {
+		t.Fatalf("待学视频 %d、未解锁视频 %d、其他节点 %d, 应为 1、2、1", course.PendingVideos, course.LockedVideos, course.OtherNodes)
+	}
+	// 学了一半的6分钟 + 按时间解锁的5分钟 + 被锁住的2分钟
+	if want := 6*60 + 5*60 + 2*60; course.Remaining != want || result.Remaining != want {
+		t.Fatalf("剩余时长为 %d/%d 秒, 应为 %d 秒", course.Remaining, result.Remaining, want)
+	}
+	wants := []struct {
+		pending     bool
+		description string
+	}{
+		{false, "已完成"},
+		{true, ""},
+		{true, unlock.Format("2006-01-02 15:04") + " 解锁"},
+		{true, "未解锁"},
+		{false, "非视频, 需要手动完成"},
+	}
+	for index, want := range wants {
+		node := course.Nodes[index]
+		if node.Pending != want.pending || node.Description != want.description {
+			t.Fatalf("节点 %s 为 %v/%q, 应为 %v/%q", node.Name, node.Pending, node.Description, want.pending, want.description)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/util"
+	"io"
+	"text/tabwriter"
+)
+
+// Print 以表格形式输出学习计划, verbose 为 true 时列出每个节点
+func Print(writer io.Writer, plans []User, verbose bool) error {
+	total := 0
+	for _, user := range plans {
+		if user.Error != "" {
+			fmt.Fprintf(writer, "账号 %s: %s\n\n", user.User, user.Error)
+			continue
+		}
+		total += user.Remaining
+		fmt.Fprintf(writer, "账号 %s, 共 %d 门课程, 剩余视频 %s\n", user.User, len(user.Courses), util.FormatSeconds(user.Remaining))
+		table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "  ID\t课程\t进度\t待学视频\t未解锁\t非视频\t剩余时长\t说明")
+		for _, course := range user.Courses {
+			description := course.Skipped
+			if course.Excluded != "" {
+				description = "选课规则排除: " + course.Excluded
+			}
+			if course.Error != "" {
+				description = course.Error
+			}
+			fmt.Fprintf(table, "  %d\t%s\t%.0f%%\t%d\t%d\t%d\t%s\t%s\n", course.ID, course.Name, course.Progress*100,
+				course.PendingVideos, course.LockedVideos, course.OtherNodes, util.FormatSeconds(course.Remaining), description)
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+		if verbose {
+			for _, course := range user.Courses {
+				if len(course.Nodes) == 0 {
+					continue
+				}
+				fmt.Fprintf(writer, "\n  [%s]\n", course.Name)
+				table = tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(table, "    章节\t节点\t类型\t状态\t时长\t剩余\t说明")
+				for _, node := range course.Nodes {
+					fmt.Fprintf(table, "    %s\t%s\t%s\t%d\t%s\t%s\t%s\n", node.Chapter, node.Name, node.Kind,
+						node.VideoState, util.FormatSeconds(node.Duration), util.FormatSeconds(node.Remaining), node.Description)
+				}
+				if err := table.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintln(writer)
+	}
+	fmt.Fprintf(writer, "合计剩余视频时长: %s\n", util.FormatSeconds(total))
+	return nil
+}
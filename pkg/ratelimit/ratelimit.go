@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶限速器
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	waits    int64
+	waited   time.Duration
+	requests int64
+}
+
+// NewLimiter 每秒 rate 个请求, 桶容量为 rate 向上取整, 至少为1
+func NewLimiter(rate float64) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.setRate(rate)
+	l.tokens = l.burst
+	return l
+}
+
+func (l *Limiter) setRate(rate float64) {
+	l.rate = rate
+	l.burst = math.Max(1, math.Ceil(rate))
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Rate 每秒请求数
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// reserve 取一个令牌, 返回需要等待的时间
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests++
+	if l.rate <= 0 {
+		return 0
+	}
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.waits++
+	l.waited += wait
+	return wait
+}
+
+// Wait 等待到可以发送请求, 返回等待的时间, ctx 为nil时不会中断
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	wait := l.reserve(time.Now())
+	if wait <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	if ctx == nil {
+		<-timer.C
+		return wait, nil
+	}
+	select {
+	case <-timer.C:
+		return wait, nil
+	case <-ctx.Done():
+		return wait, ctx.Err()
+	}
+}
+
+// Stat 限速统计
+type Stat struct {
+	Host     string  `json:"host"`
+	Rate     float64 `json:"rate"`
+	Requests int64   `json:"requests"`
+	Waits    int64   `json:"waits"`
+	// Waited 累计等待时间, 秒
+	Waited float64 `json:"waited"`
+}
+
+var limiters = struct {
+	sync.Mutex
+	data map[string]*Limiter
+}{data: map[string]*Limiter{}}
+
+// For 返回 host 共用的限速器, 同一个 host 设置了不同的速率时使用较小的一个, rate 为0表示不限速
+func For(host string, rate float64) *Limiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+	l, ok := limiters.data[host]
+	if !ok {
+		l = NewLimiter(rate)
+		limiters.data[host] = l
+		return l
+	}
+	l.mu.Lock()
+	if rate > 0 && (l.rate <= 0 || rate < l.rate) {
+		l.setRate(rate)
+	}
+	l.mu.Unlock()
+	return l
+}
+
+// Stats 全部 host 的限速统计
+func Stats() []Stat {
+	limiters.Lock()
+	defer limiters.Unlock()
+	stats := make([]Stat, 0, len(limiters.data))
+	for host, l := range limiters.data {
+		l.mu.Lock()
+		stats = append(stats, Stat{
+			Host:     host,
+			Rate:     l.rate,
+			Requests: l.requests,
+			Waits:    l.waits,
+			Waited:   l.waited.Seconds(),
+		})
+		l.mu.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// start 模拟时钟的起点, reserve 按传入的时间计算, 不需要真的等待
+var start = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+func newTestLimiter(rate float64) *Limiter {
+	l := NewLimiter(rate)
+	l.last = start
+	return l
+}
+
+func TestReserve(t *testing.T) {
+	type call struct {
+		// after 距离起点的时间
+		after time.Duration
+		wait  time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		calls []call
+	}{
+		{name: "桶满时可以连续请求", rate: 2, calls: []call{{0, 0}, {0, 0}, {0, 500 * time.Millisecond}, {0, time.Second}}},
+		{name: "按速率补充令牌", rate: 2, calls: []call{{0, 0}, {0, 0}, {500 * time.Millisecond, 0}, {500 * time.Millisecond, 500 * time.Millisecond}}},
+		{name: "补充的令牌不超过桶容量", rate: 2, calls: []call{{0, 0}, {time.Hour, 0}, {time.Hour, 0}, {time.Hour, 500 * time.Millisecond}}},
+		{name: "小于1的速率桶容量为1", rate: 0.5, calls: []call{{0, 0}, {0, 2 * time.Second}, {3 * time.Second, time.Second}}},
+		{name: "速率为0不限速", rate: 0, calls: []call{{0, 0}, {0, 0}, {0, 0}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newTestLimiter(test.rate)
+			for index, call := range test.calls {
+				if wait := l.reserve(start.Add(call.after)); wait != call.wait {
+					t.Fatalf("第 %d 个请求等待 %s, 应为 %s", index+1, wait, call.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestForSharesPerHost(t *testing.T) {
+	// 限速器按 host 全局共用, 每次使用新的 host
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	hostA, hostB := "limit-a-"+suffix+".test", "limit-b-"+suffix+".test"
+	a := For(hostA, 2)
+	if For(hostA, 5) != a {
+		t.Fatal("同一个 host 应共用限速器")
+	}
+	if a.Rate() != 2 {
+		t.Fatalf("同一个 host 应使用较小的速率, 得到 %v", a.Rate())
+	}
+	if For(hostA, 1); a.Rate() != 1 {
+		t.Fatalf("设置了更小的速率后应使用该速率, 得到 %v", a.Rate())
+	}
+
+	b := For(hostB, 1)
+	if b == a {
+		t.Fatal("不同 host 应使用不同的限速器")
+	}
+	a.last, b.last = start, start
+	a.reserve(start)
+	if wait := a.reserve(start); wait != time.Second {
+		t.Fatalf("limit-a 的令牌用完后应等待1秒, 得到 %s", wait)
+	}
+	if wait := b.reserve(start); wait != 0 {
+		t.Fatalf("limit-a 的请求不应影响 limit-b, 等待了 %s", wait)
+	}
+
+	for _, stat := range Stats() {
+		if stat.Host == hostA && (stat.Requests != 2 || stat.Waits != 1 || stat.Waited != 1) {
+			t.Fatalf("limit-a 的统计不正确: %+v", stat)
+		}
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	l := NewLimiter(0.001)
+	if wait, err := l.Wait(context.Background()); wait != 0 || err != nil {
+		t.Fatalf("桶中有令牌时不应等待, 得到 %s/%v", wait, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消后应返回 context.Canceled, 得到 %v", err)
+	}
+}
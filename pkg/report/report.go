@@ -0,0 +1,89 @@
+package report
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"time"
+)
+
+// Course 课程进度
+type Course struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	Lecturer     string  `json:"lecturer"`
+	StartDate    string  `json:"start_date"`
+	EndDate      string  `json:"end_date"`
+	Progress     string  `json:"progress"`
+	VideoLearned int     `json:"video_learned"`
+	VideoCount   int     `json:"video_count"`
+	Score        float32 `json:"score"`
+	Rank         int     `json:"rank"`
+	Credit       string  `json:"credit"`
+}
+
+// User 账号的课程进度
+type User struct {
+	User    string   `json:"user"`
+	Error   string   `json:"error,omitempty"`
+	Courses []Course `json:"courses"`
+}
+
+// Report 进度报告
+type Report struct {
+	Time  time.Time `json:"time"`
+	Users []User    `json:"users"`
+}
+
+// NewCourse 从平台返回的课程生成课程进度
+func NewCourse(course types.CoursesList) Course {
+	lecturer := course.Lecturers
+	if lecturer == "" {
+		lecturer = course.LecturerName
+	}
+	return Course{
+		ID:           course.ID,
+		Name:         course.Name,
+		Lecturer:     lecturer,
+		StartDate:    course.StartDate,
+		EndDate:      course.EndDate,
+		Progress:     course.Progress1,
+		VideoLearned: course.VideoLearned,
+		VideoCount:   course.VideoCount,
+		Score:        course.ResultScore,
+		Rank:         course.ResultRank,
+		Credit:       course.Credit,
+	}
+}
+
+// NewUser 用已经获取的课程列表生成账号的课程进度
+func NewUser(user string, courses []types.CoursesList) User {
+	result := User{User: user}
+	for _, course := range courses {
+		result.Courses = append(result.Courses, NewCourse(course))
+	}
+	return result
+}
+
+// Build 登录全部账号并获取课程, 生成进度报告, 不会提交任何学习记录
+func Build(users []config.User) Report {
+	report := Report{Time: time.Now()}
+	for _, user := range users {
+		report.Users = append(report.Users, BuildUser(user))
+	}
+	return report
+}
+
+// BuildUser 登录单个账号并获取课程进度
+func BuildUser(user config.User) User {
+	instance := yinghua.New(user)
+	err := instance.Login()
+	if err != nil {
+		return User{User: user.Key(), Error: "登录失败: " + err.Error()}
+	}
+	err = instance.GetCourses()
+	if err != nil {
+		return User{User: user.Key(), Error: "获取课程失败: " + err.Error()}
+	}
+	return NewUser(user.Key(), instance.Courses)
+}
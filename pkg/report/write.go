@@ -0,0 +1,205 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 报告格式
+const (
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Formats 支持的报告格式
+var Formats = []string{FormatCSV, FormatMarkdown, FormatHTML}
+
+// headers 报告的列
+var headers = []string{"课程ID", "课程", "讲师", "开始时间", "结束时间", "进度", "视频", "成绩", "排名", "学分"}
+
+// ParseFormat 解析报告格式, 支持 md、htm 等简写
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "csv":
+		return FormatCSV, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "htm", "html":
+		return FormatHTML, nil
+	}
+	return "", fmt.Errorf("不支持的报告格式: %s, 可选 %s", format, strings.Join(Formats, "、"))
+}
+
+// FormatOf 根据文件扩展名判断报告格式
+func FormatOf(path string) (string, error) {
+	return ParseFormat(filepath.Ext(path))
+}
+
+// ContentType 报告格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// Extension 报告格式对应的文件扩展名
+func Extension(format string) string {
+	if format == FormatMarkdown {
+		return ".md"
+	}
+	return "." + format
+}
+
+// Write 以指定格式输出进度报告
+func Write(writer io.Writer, format string, report Report) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(writer, report)
+	case FormatMarkdown:
+		return WriteMarkdown(writer, report)
+	case FormatHTML:
+		return WriteHTML(writer, report)
+	}
+	_, err := ParseFormat(format)
+	return err
+}
+
+func row(course Course) []string {
+	return []string{
+		strconv.Itoa(course.ID),
+		course.Name,
+		course.Lecturer,
+		course.StartDate,
+		course.EndDate,
+		course.Progress,
+		fmt.Sprintf("%d/%d", course.VideoLearned, course.VideoCount),
+		strconv.FormatFloat(float64(course.Score), 'f', -1, 32),
+		strconv.Itoa(course.Rank),
+		course.Credit,
+	}
+}
+
+// WriteCSV 输出CSV, 每行一门课程, 第一列为账号
+func WriteCSV(writer io.Writer, report Report) error {
+	// 写入BOM, 否则Excel打开时中文乱码
+	if _, err := io.WriteString(writer, "\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(writer)
+	if err := w.Write(append([]string{"账号", "错误"}, headers...)); err != nil {
+		return err
+	}
+	for _, user := range report.Users {
+		if user.Error != "" {
+			if err := w.Write([]string{user.User, user.Error}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, course := range user.Courses {
+			if err := w.Write(append([]string{user.User, ""}, row(course)...)); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// markdownEscape 转义表格中的竖线和换行
+var markdownEscape = strings.NewReplacer("|", "\\|", "\r", "", "\n", " ")
+
+// WriteMarkdown 输出Markdown, 每个账号一个表格
+func WriteMarkdown(writer io.Writer, report Report) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# 学习进度报告\n\n生成时间: %s\n", report.Time.Format("2006-01-02 15:04:05"))
+	for _, user := range report.Users {
+		fmt.Fprintf(&builder, "\n## %s\n\n", markdownEscape.Replace(user.User))
+		if user.Error != "" {
+			fmt.Fprintf(&builder, "> %s\n", markdownEscape.Replace(user.Error))
+			continue
+		}
+		if len(user.Courses) == 0 {
+			builder.WriteString("没有课程\n")
+			continue
+		}
+		builder.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+		builder.WriteString(strings.Repeat("| --- ", len(headers)) + "|\n")
+		for _, course := range user.Courses {
+			cells := row(course)
+			for i := range cells {
+				cells[i] = markdownEscape.Replace(cells[i])
+			}
+			builder.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+	}
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// htmlTemplate 不依赖外部资源的HTML页面, 可以直接发送给别人打开
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>学习进度报告</title>
+<style>
+body { font-family: -apple-system, "Microsoft YaHei", sans-serif; margin: 24px; color: #333; }
+h2 { margin-top: 32px; font-size: 18px; }
+table { border-collapse: collapse; width: 100%; font-size: 14px; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; }
+th { background: #f5f5f5; }
+tr:nth-child(even) td { background: #fafafa; }
+.time { color: #888; font-size: 13px; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>学习进度报告</h1>
+<p class="time">生成时间: {{.Time.Format "2006-01-02 15:04:05"}}</p>
+{{range .Users}}
+<h2>{{.User}}</h2>
+{{if .Error}}<p class="error">{{.Error}}</p>
+{{else if not .Rows}}<p>没有课程</p>
+{{else}}<table>
+<tr>{{range $.Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML 输出完整的HTML页面, 每个账号一个表格
+func WriteHTML(writer io.Writer, report Report) error {
+	type userRows struct {
+		User  string
+		Error string
+		Rows  [][]string
+	}
+	data := struct {
+		Time    time.Time
+		Headers []string
+		Users   []userRows
+	}{Time: report.Time, Headers: headers}
+	for _, user := range report.Users {
+		item := userRows{User: user.User, Error: user.Error}
+		for _, course := range user.Courses {
+			item.Rows = append(item.Rows, row(course))
+		}
+		data.Users = append(data.Users, item)
+	}
+	return htmlTemplate.Execute(writer, data)
+}
@@ -0,0 +1,54 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// 因服务器熔断暂停的任务, key为任务标识
+var breakerPaused = struct {
+	sync.Mutex
+	data map[string]Task
+}{data: map[string]Task{}}
+
+func init() {
+	breaker.Subscribe(onBreakerChange)
+}
+
+// onBreakerChange 服务器熔断时暂停该域名下正在学习的任务, 恢复后继续
+func onBreakerChange(change breaker.Change) {
+	switch {
+	case change.To == breaker.StateOpen && change.From == breaker.StateClosed:
+		logrus.Warnf("[%s] 连续请求失败, 暂停该学校的全部任务, 等待服务器恢复: %v", change.Host, change.Err)
+		for _, state := range States() {
+			if state.Status != StatusRunning || state.task.User.Host() != change.Host {
+				continue
+			}
+			breakerPaused.Lock()
+			breakerPaused.data[state.Key] = state.task
+			breakerPaused.Unlock()
+			emit(state.task, StatusPaused, state.Progress, "学校服务器无法访问, 等待恢复", change.Err)
+		}
+	case change.To == breaker.StateOpen:
+		logrus.Infof("[%s] 服务器仍然无法访问: %v", change.Host, change.Err)
+	case change.To == breaker.StateHalfOpen:
+		logrus.Debugf("[%s] 正在探测服务器是否恢复", change.Host)
+	case change.To == breaker.StateClosed:
+		logrus.Infof("[%s] 服务器已恢复, 继续学习", change.Host)
+		breakerPaused.Lock()
+		var resumed []Task
+		for key, task := range breakerPaused.data {
+			if task.User.Host() == change.Host {
+				resumed = append(resumed, task)
+				delete(breakerPaused.data, key)
+			}
+		}
+		breakerPaused.Unlock()
+		for _, task := range resumed {
+			emit(task, StatusRunning, currentProgress(task), "服务器已恢复", nil)
+		}
+		// 唤醒等待该域名的调度
+		queue.cond.Broadcast()
+	}
+}
@@ -0,0 +1,160 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoint 任务断点, 记录课程已完成的节点
+type Checkpoint struct {
+	User      string    `json:"user"`
+	CourseID  int       `json:"course_id"`
+	Course    string    `json:"course"`
+	Nodes     []int     `json:"nodes"`
+	LastNode  int       `json:"last_node"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 断点修改后延迟写入, 同一时间内的多次修改只写一次
+const checkpointDelay = 2 * time.Second
+
+// 写入断点时轮转备份的最小间隔, 结束运行时总是轮转
+const checkpointBackupInterval = 10 * time.Minute
+
+var checkpoints = struct {
+	sync.Mutex
+	loaded bool
+	data   map[string]*Checkpoint
+	// dirty 有修改还没有写入文件
+	dirty bool
+	timer *time.Timer
+	// backedUp 上一次轮转备份的时间
+	backedUp time.Time
+}{data: map[string]*Checkpoint{}}
+
+// CheckpointPath 断点文件路径
+func CheckpointPath() string {
+	return filepath.Join(config.DataDir, "checkpoint.json")
+}
+
+// LoadCheckpoints 读取断点文件
+func LoadCheckpoints() (map[string]Checkpoint, error) {
+	checkpoints.Lock()
+	defer checkpoints.Unlock()
+	err := loadCheckpoints()
+	result := make(map[string]Checkpoint, len(checkpoints.data))
+	for key, checkpoint := range checkpoints.data {
+		result[key] = *checkpoint
+	}
+	return result, err
+}
+
+func loadCheckpoints() error {
+	if checkpoints.loaded {
+		return nil
+	}
+	data := map[string]*Checkpoint{}
+	err := util.LoadJson(CheckpointPath(), &data)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	checkpoints.data = data
+	checkpoints.loaded = true
+	return nil
+}
+
+// updateCheckpoint 修改任务断点, 稍后写入文件
+func updateCheckpoint(task Task, update func(checkpoint *Checkpoint)) error {
+	checkpoints.Lock()
+	defer checkpoints.Unlock()
+	if err := loadCheckpoints(); err != nil {
+		return err
+	}
+	key := task.Key()
+	checkpoint, ok := checkpoints.data[key]
+	if !ok {
+		checkpoint = &Checkpoint{
+			User:     task.User.Key(),
+			CourseID: task.Course.ID,
+			Course:   task.Course.Name,
+		}
+		checkpoints.data[key] = checkpoint
+	}
+	update(checkpoint)
+	checkpoint.UpdatedAt = time.Now()
+	checkpoints.dirty = true
+	if checkpoints.timer == nil {
+		checkpoints.timer = time.AfterFunc(checkpointDelay, func() {
+			if err := flushCheckpoints(false); err != nil {
+				logrus.Warnf("保存断点失败: %s", err.Error())
+			}
+		})
+	}
+	return nil
+}
+
+// FlushCheckpoints 立即写入还没有保存的断点并轮转备份, 结束运行时调用
+func FlushCheckpoints() error {
+	return flushCheckpoints(true)
+}
+
+// flushCheckpoints 写入断点文件, 距离上次备份超过 checkpointBackupInterval 或 final 为 true 时轮转备份
+func flushCheckpoints(final bool) error {
+	checkpoints.Lock()
+	defer checkpoints.Unlock()
+	if checkpoints.timer != nil {
+		checkpoints.timer.Stop()
+		checkpoints.timer = nil
+	}
+	if !checkpoints.dirty {
+		return nil
+	}
+	backups := 0
+	if final || time.Since(checkpoints.backedUp) >= checkpointBackupInterval {
+		backups = util.DefaultBackups
+	}
+	err := util.SaveJsonWith(CheckpointPath(), checkpoints.data, 0644, backups)
+	if err != nil {
+		return err
+	}
+	checkpoints.dirty = false
+	if backups > 0 {
+		checkpoints.backedUp = time.Now()
+	}
+	return nil
+}
+
+// doneNodes 断点中已完成的节点
+func doneNodes(task Task) map[int]bool {
+	checkpoints.Lock()
+	defer checkpoints.Unlock()
+	nodes := map[int]bool{}
+	if err := loadCheckpoints(); err != nil {
+		return nodes
+	}
+	if checkpoint, ok := checkpoints.data[task.Key()]; ok {
+		for _, id := range checkpoint.Nodes {
+			nodes[id] = true
+		}
+	}
+	return nodes
+}
+
+// checkpointNode 记录学完的节点, 已经在断点中的节点不重复记录
+func checkpointNode(task Task, id int) error {
+	return updateCheckpoint(task, func(checkpoint *Checkpoint) {
+		checkpoint.LastNode = id
+		for _, node := range checkpoint.Nodes {
+			if node == id {
+				return
+			}
+		}
+		checkpoint.Nodes = append(checkpoint.Nodes, id)
+	})
+}
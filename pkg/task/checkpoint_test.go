@@ -0,0 +1,32 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestCheckpointNodeSkipsRecordedNodes(t *testing.T) {
+	useTempDir(t)
+	task := Task{User: config.User{Username: "grace", BaseURL: "https://checkpoint.example.com"}, Course: types.CoursesList{ID: 5, Name: "课程5"}}
+	// 重新运行和核对后重新学习时, 同一个节点会再次学完
+	for _, id := range []int{51, 52, 51, 52} {
+		if err := checkpointNode(task, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := requeueNodes(task, []int{52}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpointNode(task, 52); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, ok := loadedCheckpoint(t, task)
+	if !ok || len(checkpoint.Nodes) != 2 || checkpoint.Nodes[0] != 51 || checkpoint.Nodes[1] != 52 {
+		t.Fatalf("断点中的节点应为 [51 52], 得到 %+v", checkpoint)
+	}
+	if checkpoint.LastNode != 52 {
+		t.Fatalf("最后学完的节点应为52, 得到 %d", checkpoint.LastNode)
+	}
+}
@@ -0,0 +1,353 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Estimate 剩余学习时长和预计完成时间
+type Estimate struct {
+	Remaining int       `json:"remaining"`
+	ETA       time.Time `json:"eta"`
+}
+
+// estimate 单个任务的剩余时长估算
+type estimate struct {
+	task     Task
+	order    int
+	initial  int
+	total    int
+	nodes    map[int]int
+	running  bool
+	finished bool
+}
+
+// remaining 剩余学习时长, 获取章节前使用入队时的估算值
+func (e *estimate) remaining() int {
+	if e.finished {
+		return 0
+	}
+	if e.nodes == nil {
+		return e.initial
+	}
+	sum := 0
+	for _, seconds := range e.nodes {
+		sum += seconds
+	}
+	return sum
+}
+
+// progress 按视频时长计算的课程进度, 未获取章节时返回-1
+func (e *estimate) progress() float64 {
+	if e.nodes == nil || e.total == 0 {
+		return -1
+	}
+	return 1 - float64(e.remaining())/float64(e.total)
+}
+
+var estimates = struct {
+	sync.Mutex
+	seq  int
+	data map[string]*estimate
+}{data: map[string]*estimate{}}
+
+// trackStatus 根据任务状态更新估算
+func trackStatus(task Task, status Status) {
+	estimates.Lock()
+	defer estimates.Unlock()
+	item, ok := estimates.data[task.Key()]
+	if !ok {
+		estimates.seq++
+		item = &estimate{task: task, order: estimates.seq, initial: task.Estimate}
+		estimates.data[task.Key()] = item
+	}
+	switch status {
+	case StatusPending, StatusPaused, StatusWaiting:
+		item.running = false
+		item.finished = false
+	case StatusRunning:
+		item.running = true
+		item.finished = false
+	case StatusDone, StatusSkipped, StatusFailed:
+		item.running = false
+		item.finished = true
+	}
+}
+
+// currentProgress 任务当前进度, 按视频时长计算, 还没有获取章节时使用课程进度
+func currentProgress(task Task) float64 {
+	estimates.Lock()
+	defer estimates.Unlock()
+	if item, ok := estimates.data[task.Key()]; ok {
+		if progress := item.progress(); progress >= 0 {
+			return progress
+		}
+	}
+	return float64(task.Course.Progress)
+}
+
+// trackChapters 获取到章节后按节点重新估算
+func trackChapters(task Task, chapters []types.ChaptersList) {
+	estimates.Lock()
+	item, ok := estimates.data[task.Key()]
+	if ok {
+		item.nodes = map[int]int{}
+		item.total = 0
+		for _, chapter := range chapters {
+			for _, node := range chapter.NodeList {
+				if !node.TabVideo {
+					continue
+				}
+				item.nodes[node.ID] = node.RemainingSeconds()
+				item.total += node.VideoSeconds()
+			}
+		}
+	}
+	estimates.Unlock()
+	publish(task, "", nil)
+}
+
+// trackProgress 节点进度变化后重新估算
+func trackProgress(task Task, nodeID int, remaining int) {
+	estimates.Lock()
+	item, ok := estimates.data[task.Key()]
+	if ok && item.nodes != nil {
+		if _, exists := item.nodes[nodeID]; exists {
+			item.nodes[nodeID] = remaining
+		}
+	}
+	estimates.Unlock()
+	publish(task, "", nil)
+}
+
+// project 模拟任务调度, 按协程数和账号并发限制推算每个任务的完成时间
+func project(now time.Time) map[string]Estimate {
+	// 先取调度顺序, 调度器计算优先级时会获取 estimates 的锁
+	ranks := queue.order()
+	unlocks := queue.unlocks()
+
+	estimates.Lock()
+	var running, pending []*estimate
+	for _, item := range estimates.data {
+		if item.finished {
+			continue
+		}
+		if item.running {
+			running = append(running, item)
+		} else {
+			pending = append(pending, item)
+		}
+	}
+	remaining := map[string]int{}
+	for _, item := range append(running, pending...) {
+		remaining[item.task.Key()] = item.remaining()
+	}
+	estimates.Unlock()
+
+	sort.Slice(running, func(i, j int) bool { return running[i].order < running[j].order })
+	// 排队中的任务按调度器的优先级顺序推算
+	sort.Slice(pending, func(i, j int) bool {
+		a, okA := ranks[pending[i].task.Key()]
+		b, okB := ranks[pending[j].task.Key()]
+		if okA && okB && a != b {
+			return a < b
+		}
+		if okA != okB {
+			return okA
+		}
+		return pending[i].order < pending[j].order
+	})
+
+	limit := config.Conf.Global.Limit
+	if limit < len(running) {
+		limit = len(running)
+	}
+	if limit <= 0 {
+		limit = 1
+	}
+	workers := make([]int, limit)
+	slots := map[string][]int{}
+	earliest := func(list []int) int {
+		index := 0
+		for i := range list {
+			if list[i] < list[index] {
+				index = i
+			}
+		}
+		return index
+	}
+	result := map[string]Estimate{}
+	assign := func(item *estimate) {
+		key := item.task.Key()
+		worker := earliest(workers)
+		start := workers[worker]
+		userKey := item.task.User.Key()
+		userSlots, limited := slots[userKey]
+		if !limited {
+			if max := config.EffectiveSettings(item.task.User).MaxCourses; max > 0 {
+				userSlots = make([]int, max)
+				slots[userKey] = userSlots
+				limited = true
+			}
+		}
+		slot := 0
+		if limited {
+			slot = earliest(userSlots)
+			if userSlots[slot] > start {
+				start = userSlots[slot]
+			}
+		}
+		// 不在学习时段内的任务从下一个时段开始计算, 不考虑学习中途跨过时段结束
+		if next := config.EffectiveSettings(item.task.User).Schedule().Next(now); next.After(now) {
+			if wait := int(next.Sub(now).Seconds()); wait > start {
+				start = wait
+			}
+		}
+		// 等待解锁的任务从解锁时间开始计算
+		if until, ok := unlocks[key]; ok && until.After(now) {
+			if wait := int(until.Sub(now).Seconds()); wait > start {
+				start = wait
+			}
+		}
+		finish := start + remaining[key]
+		workers[worker] = finish
+		if limited {
+			userSlots[slot] = finish
+		}
+		result[key] = Estimate{
+			Remaining: remaining[key],
+			ETA:       now.Add(time.Duration(finish) * time.Second),
+		}
+	}
+	for _, item := range running {
+		assign(item)
+	}
+	for _, item := range pending {
+		assign(item)
+	}
+	return result
+}
+
+// Estimates 全部任务以及每个账号的剩余时长和预计完成时间
+func Estimates() (Estimate, map[string]Estimate) {
+	now := time.Now()
+	total := Estimate{ETA: now}
+	users := map[string]Estimate{}
+	for _, state := range States() {
+		if state.Remaining == 0 {
+			continue
+		}
+		user := users[state.User]
+		user.Remaining += state.Remaining
+		if state.ETA.After(user.ETA) {
+			user.ETA = state.ETA
+		}
+		users[state.User] = user
+		total.Remaining += state.Remaining
+		if state.ETA.After(total.ETA) {
+			total.ETA = state.ETA
+		}
+	}
+	return total, users
+}
+
+// projectInterval 两次推算完成时间的最小间隔, 学习进度每次查询都会变化, 不必每次都推算
+const projectInterval = 3 * time.Second
+
+// 推算完成时间的节流状态
+var projection = struct {
+	sync.Mutex
+	// dirty 有变化还没有重新推算
+	dirty bool
+	timer *time.Timer
+	last  time.Time
+}{}
+
+// publish 更新 changed 的剩余时长和进度并通知订阅者, 全部任务的完成时间稍后重新推算
+// from 为 changed 变更前的状态, 为空表示状态没有变化, err 附带在 changed 的事件中
+func publish(changed Task, from Status, err error) {
+	remaining, progress := -1, -1.0
+	estimates.Lock()
+	if item, ok := estimates.data[changed.Key()]; ok {
+		remaining, progress = item.remaining(), item.progress()
+	}
+	estimates.Unlock()
+	invalidate()
+
+	events.Lock()
+	state, ok := events.states[changed.Key()]
+	if !ok {
+		events.Unlock()
+		return
+	}
+	if remaining >= 0 {
+		state.Remaining = remaining
+	}
+	if progress >= 0 && state.Status == StatusRunning {
+		state.Progress = progress
+	}
+	event := state.event()
+	event.Err = err
+	if from != "" {
+		event.From = from
+	}
+	listeners := events.listeners
+	events.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// invalidate 标记需要重新推算完成时间, 距离上一次推算不足 projectInterval 时延后执行
+func invalidate() {
+	projection.Lock()
+	defer projection.Unlock()
+	projection.dirty = true
+	if projection.timer != nil {
+		return
+	}
+	delay := projectInterval - time.Since(projection.last)
+	if delay < 0 {
+		delay = 0
+	}
+	projection.timer = time.AfterFunc(delay, reproject)
+}
+
+// reproject 重新推算全部任务的完成时间, 通知完成时间发生变化的任务
+func reproject() {
+	projection.Lock()
+	projection.timer = nil
+	if !projection.dirty {
+		projection.Unlock()
+		return
+	}
+	projection.dirty = false
+	projection.last = time.Now()
+	projection.Unlock()
+
+	projections := project(time.Now())
+	var notify []Event
+	events.Lock()
+	for key, state := range events.states {
+		projected := projections[key]
+		drift := state.ETA.Sub(projected.ETA)
+		changed := state.Remaining != projected.Remaining || drift > time.Minute || drift < -time.Minute
+		state.Remaining = projected.Remaining
+		state.ETA = projected.ETA
+		if changed {
+			notify = append(notify, state.event())
+		}
+	}
+	listeners := events.listeners
+	events.Unlock()
+
+	for _, event := range notify {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
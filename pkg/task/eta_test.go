@@ -0,0 +1,47 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestPublishThrottlesProjection(t *testing.T) {
+	old := config.Conf
+	defer func() { config.Conf = old }()
+	config.Conf = config.Config{Global: config.Global{Limit: 1}}
+	startRun()
+
+	task := Task{User: config.User{Username: "henry", BaseURL: "https://eta.example.com"}, Course: types.CoursesList{ID: 41, Name: "高数"}}
+	emit(task, StatusRunning, 0, "", nil)
+	trackChapters(task, []types.ChaptersList{{NodeList: []types.ChaptersNodeList{{ID: 411, TabVideo: true, VideoDuration: "60"}}}})
+
+	projection.Lock()
+	timer := projection.timer
+	projection.Unlock()
+	for i := 1; i <= 50; i++ {
+		trackProgress(task, 411, 60-i)
+	}
+	projection.Lock()
+	same, dirty := projection.timer == timer, projection.dirty
+	projection.Unlock()
+	if timer == nil || !same || !dirty {
+		t.Fatal("连续的进度变化应合并为一次推算")
+	}
+
+	// 剩余时长和进度立即更新, 完成时间稍后推算
+	state := States()[0]
+	if state.Remaining != 10 || state.Progress <= 0.8 {
+		t.Fatalf("剩余时长和进度应立即更新, 得到 %d, %.2f", state.Remaining, state.Progress)
+	}
+	reproject()
+	projection.Lock()
+	dirty = projection.dirty
+	projection.Unlock()
+	state = States()[0]
+	if dirty || state.ETA.Before(time.Now()) || state.ETA.After(time.Now().Add(15*time.Second)) {
+		t.Fatalf("推算后的完成时间不正确: %s, dirty=%v", state.ETA, dirty)
+	}
+}
@@ -0,0 +1,183 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/history"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 学习中查询到的节点进度, 按 history_interval 定时保存, key为任务标识
+var historyProgress = struct {
+	sync.Mutex
+	data map[string]*studyingNodes
+}{data: map[string]*studyingNodes{}}
+
+// studyingNodes 任务还没有保存的节点进度
+type studyingNodes struct {
+	task  Task
+	nodes map[int]history.Node
+}
+
+// historyInterval 进度没有变化时保存快照的间隔, 账号关闭进度历史时返回 false
+func historyInterval(task Task) (time.Duration, bool) {
+	minutes := config.EffectiveSettings(task.User).HistoryInterval
+	if minutes < 0 {
+		return 0, false
+	}
+	return time.Duration(minutes) * time.Minute, true
+}
+
+// recordHistory 保存课程进度快照, chapters 为空时沿用上一次的节点状态
+func recordHistory(task Task, course types.CoursesList, chapters []types.ChaptersList) {
+	interval, ok := historyInterval(task)
+	if !ok {
+		return
+	}
+	snapshot := history.NewSnapshot(task.User.Key(), course)
+	snapshot.Nodes = history.NewNodes(chapters)
+	if _, err := history.Record(snapshot, interval); err != nil {
+		logrus.Warnf("[%s] 保存进度历史失败: %s", task.Key(), err.Error())
+	}
+}
+
+// recordHistoryNodes 只更新节点进度, 同时丢弃这些节点还没有保存的学习进度
+func recordHistoryNodes(task Task, nodes ...history.Node) {
+	interval, ok := historyInterval(task)
+	if !ok {
+		return
+	}
+	historyProgress.Lock()
+	if pending, ok := historyProgress.data[task.Key()]; ok {
+		for _, node := range nodes {
+			delete(pending.nodes, node.ID)
+		}
+		if len(pending.nodes) == 0 {
+			delete(historyProgress.data, task.Key())
+		}
+	}
+	historyProgress.Unlock()
+	if _, err := history.RecordNodes(task.User.Key(), task.Course.ID, nodes, interval); err != nil {
+		logrus.Warnf("[%s] 保存进度历史失败: %s", task.Key(), err.Error())
+	}
+}
+
+// recordHistoryProgress 记录学习中查询到的节点进度, 由定时器保存
+func recordHistoryProgress(task Task, node types.ChaptersNodeList, progress types.NodeVideoData) {
+	if _, ok := historyInterval(task); !ok {
+		return
+	}
+	item := history.NewNode("", node)
+	item.Studied = progress.VideoDuration - progress.RemainingSeconds()
+	if progress.StudyTotal.State == "2" {
+		item.VideoState = 2
+	}
+	historyProgress.Lock()
+	defer historyProgress.Unlock()
+	pending, ok := historyProgress.data[task.Key()]
+	if !ok {
+		pending = &studyingNodes{task: task, nodes: map[int]history.Node{}}
+		historyProgress.data[task.Key()] = pending
+	}
+	pending.nodes[node.ID] = item
+}
+
+// flushHistory 保存学习中的节点进度, 距离上一次快照不足 history_interval 的任务留到下一次
+func flushHistory() {
+	historyProgress.Lock()
+	pending := make([]*studyingNodes, 0, len(historyProgress.data))
+	for _, item := range historyProgress.data {
+		copied := &studyingNodes{task: item.task, nodes: map[int]history.Node{}}
+		for id, node := range item.nodes {
+			copied.nodes[id] = node
+		}
+		pending = append(pending, copied)
+	}
+	historyProgress.Unlock()
+
+	for _, item := range pending {
+		interval, ok := historyInterval(item.task)
+		if !ok {
+			continue
+		}
+		nodes := make([]history.Node, 0, len(item.nodes))
+		for _, node := range item.nodes {
+			nodes = append(nodes, node)
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].ID < nodes[j].ID
+		})
+		saved, err := history.RecordNodes(item.task.User.Key(), item.task.Course.ID, nodes, interval)
+		if err != nil {
+			logrus.Warnf("[%s] 保存进度历史失败: %s", item.task.Key(), err.Error())
+			continue
+		}
+		if saved {
+			historyProgress.Lock()
+			delete(historyProgress.data, item.task.Key())
+			historyProgress.Unlock()
+		}
+	}
+}
+
+// historyPeriod 定时保存的周期, 取本次运行的账号中最短的 history_interval, 都不保存时返回0
+func historyPeriod(tasks []Task) time.Duration {
+	var period time.Duration
+	for _, task := range tasks {
+		if interval, ok := historyInterval(task); ok && interval > 0 && (period == 0 || interval < period) {
+			period = interval
+		}
+	}
+	return period
+}
+
+// pruneHistory 清理 history_days 天之前的快照
+func pruneHistory() {
+	days := config.DefaultSettings().Merge(config.Conf.Global.Settings).HistoryDays
+	removed, err := history.Prune(days)
+	if err != nil {
+		logrus.Warnf("清理进度历史失败: %s", err.Error())
+	} else if removed > 0 {
+		logrus.Infof("已清理 %d 天前的进度历史 %d 条", days, removed)
+	}
+}
+
+// startHistory 开始运行时清理过期的快照, 之后按 history_interval 定时保存学习中的节点进度并清理, 返回停止函数
+func startHistory(tasks []Task) func() {
+	pruneHistory()
+	period := historyPeriod(tasks)
+	if period <= 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(period)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				flushHistory()
+				pruneHistory()
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		flushHistory()
+	}
+}
+
+// closeHistory 全部任务结束后关闭数据库
+func closeHistory() {
+	if err := history.Close(); err != nil {
+		logrus.Warnf("关闭进度历史失败: %s", err.Error())
+	}
+}
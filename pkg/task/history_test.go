@@ -0,0 +1,84 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/history"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestFlushHistoryRecordsStudyingNodes(t *testing.T) {
+	useTempDir(t)
+	t.Cleanup(func() { _ = history.Close() })
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{HistoryInterval: 1}}}
+	user := config.User{Username: "grace", BaseURL: "https://history.example.com"}
+	task := Task{User: user, Course: types.CoursesList{ID: 31, Name: "高数"}}
+	node := types.ChaptersNodeList{ID: 311, Name: "第一节", TabVideo: true, VideoDuration: "120"}
+
+	// 登录时保存的快照
+	snapshot := history.NewSnapshot(user.Key(), task.Course)
+	snapshot.Time = time.Now().Add(-2 * time.Minute)
+	snapshot.Nodes = []history.Node{{ID: 311, Name: "第一节", Chapter: "第一章"}}
+	if _, err := history.Record(snapshot, 0); err != nil {
+		t.Fatal(err)
+	}
+	progress := func(studied string) types.NodeVideoData {
+		return types.NodeVideoData{VideoDuration: 120, StudyTotal: types.NodeVideoStudyTotal{Duration: studied, Progress: "0.50", State: "1"}}
+	}
+	since := time.Now().Add(-time.Hour)
+
+	recordHistoryProgress(task, node, progress("40"))
+	flushHistory()
+	snapshots, err := history.Course(user.Key(), task.Course.ID, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("超过间隔后应保存学习中的进度, 得到 %d 条快照", len(snapshots))
+	}
+	if got := snapshots[1].Nodes[0]; got.Studied != 40 || got.Chapter != "第一章" {
+		t.Fatalf("节点进度不正确: %+v", got)
+	}
+
+	// 不足间隔时留到下一次
+	recordHistoryProgress(task, node, progress("80"))
+	flushHistory()
+	if snapshots, _ = history.Course(user.Key(), task.Course.ID, since); len(snapshots) != 2 {
+		t.Fatalf("不足间隔时不应保存, 得到 %d 条快照", len(snapshots))
+	}
+	historyProgress.Lock()
+	pending := len(historyProgress.data)
+	historyProgress.Unlock()
+	if pending != 1 {
+		t.Fatal("没有保存的进度应留到下一次")
+	}
+
+	// 节点完成后丢弃旧的学习进度, 避免覆盖完成状态
+	finished := history.NewNode("", node)
+	finished.VideoState, finished.Studied = 2, 120
+	recordHistoryNodes(task, finished)
+	flushHistory()
+	snapshots, _ = history.Course(user.Key(), task.Course.ID, since)
+	if got := snapshots[len(snapshots)-1].Nodes[0]; got.VideoState != 2 || got.Studied != 120 {
+		t.Fatalf("完成状态被覆盖: %+v", got)
+	}
+}
+
+func TestHistoryPeriod(t *testing.T) {
+	old := config.Conf
+	defer func() { config.Conf = old }()
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{HistoryInterval: 30}}}
+	tasks := []Task{
+		{User: config.User{Username: "a"}},
+		{User: config.User{Username: "b", Settings: config.Settings{HistoryInterval: 5}}},
+		{User: config.User{Username: "c", Settings: config.Settings{HistoryInterval: -1}}},
+	}
+	if got := historyPeriod(tasks); got != 5*time.Minute {
+		t.Fatalf("应取最短的间隔, 得到 %s", got)
+	}
+	if got := historyPeriod(tasks[2:]); got != 0 {
+		t.Fatalf("都不保存时应为0, 得到 %s", got)
+	}
+}
@@ -0,0 +1,30 @@
+package task
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/report"
+	"time"
+)
+
+// Report 用运行中已经获取的课程信息生成进度报告, 不会重新登录
+// user 为账号标识或用户名, 为空时包括全部账号, 没有对应的任务时返回 false
+func Report(user string) (report.Report, bool) {
+	result := report.Report{Time: time.Now()}
+	for _, state := range States() {
+		if !state.belongsTo(user) {
+			continue
+		}
+		// 学习中的课程使用当前进度
+		course := state.task.Course
+		if state.Progress > float64(course.Progress) {
+			course.Progress = float32(state.Progress)
+			course.Progress1 = fmt.Sprintf("%.1f%%", state.Progress*100)
+		}
+		if len(result.Users) == 0 || result.Users[len(result.Users)-1].User != state.User {
+			result.Users = append(result.Users, report.User{User: state.User})
+		}
+		last := &result.Users[len(result.Users)-1]
+		last.Courses = append(last.Courses, report.NewCourse(course))
+	}
+	return result, len(result.Users) > 0
+}
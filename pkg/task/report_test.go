@@ -0,0 +1,41 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestReportUsesEngineData(t *testing.T) {
+	alice := config.User{Username: "alice", BaseURL: "https://mooc.example.com"}
+	bob := config.User{Username: "bob", BaseURL: "https://mooc.example.com"}
+	first := Task{User: alice, Course: types.CoursesList{ID: 1, Name: "高数", Progress: 0.2, Progress1: "20%"}}
+	second := Task{User: alice, Course: types.CoursesList{ID: 2, Name: "英语", Progress: 1, Progress1: "100%"}}
+	third := Task{User: bob, Course: types.CoursesList{ID: 3, Name: "物理", Progress: 0.5, Progress1: "50%"}}
+	startRun()
+	emit(first, StatusRunning, 0.6, "", nil)
+	emit(second, StatusSkipped, 1, "课程已完成", nil)
+	emit(third, StatusPending, 0.5, "", nil)
+	// 核对时重新获取的课程信息
+	updateCourse(third, types.CoursesList{ID: 3, Name: "物理", Progress: 0.7, Progress1: "70%", VideoLearned: 7})
+
+	result, ok := Report("")
+	if !ok || len(result.Users) != 2 {
+		t.Fatalf("应包括2个账号, 得到 %+v", result.Users)
+	}
+	if got := result.Users[0].Courses; len(got) != 2 || got[0].Progress != "60.0%" || got[1].Progress != "100%" {
+		t.Fatalf("学习中的课程应使用当前进度, 得到 %+v", got)
+	}
+	if got := result.Users[1].Courses[0]; got.Progress != "70%" || got.VideoLearned != 7 {
+		t.Fatalf("应使用重新获取的课程信息, 得到 %+v", got)
+	}
+
+	result, ok = Report("bob")
+	if !ok || len(result.Users) != 1 || result.Users[0].User != bob.Key() {
+		t.Fatalf("按用户名筛选失败, 得到 %+v", result.Users)
+	}
+	if _, ok := Report("carol"); ok {
+		t.Fatal("没有任务的账号应返回 false")
+	}
+}
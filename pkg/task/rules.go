@@ -0,0 +1,116 @@
+package task
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExcludeReason 按账号的选课规则检查课程, 返回排除课程的规则, 课程需要学习时返回空字符串
+func ExcludeReason(user config.User, course types.CoursesList) string {
+	rules := user.Courses
+	if rules.Empty() {
+		return ""
+	}
+
+	exclude := rules.Exclude
+	if containsID(exclude.IDs, course.ID) {
+		return fmt.Sprintf("exclude.ids 包含 %d", course.ID)
+	}
+	if pattern := matchName(exclude.Names, course.Name); pattern != "" {
+		return fmt.Sprintf("exclude.names 匹配 %q", pattern)
+	}
+	if containsText(exclude.Categories, course.CategoryName) {
+		return fmt.Sprintf("exclude.categories 包含 %q", course.CategoryName)
+	}
+	if containsText(exclude.Colleges, course.CollegeName) {
+		return fmt.Sprintf("exclude.colleges 包含 %q", course.CollegeName)
+	}
+
+	include := rules.Include
+	if len(include.IDs) > 0 && !containsID(include.IDs, course.ID) {
+		return fmt.Sprintf("include.ids 不包含 %d", course.ID)
+	}
+	if len(include.Names) > 0 && matchName(include.Names, course.Name) == "" {
+		return "include.names 均不匹配"
+	}
+	if len(include.Categories) > 0 && !containsText(include.Categories, course.CategoryName) {
+		return fmt.Sprintf("include.categories 不包含 %q", course.CategoryName)
+	}
+	if len(include.Colleges) > 0 && !containsText(include.Colleges, course.CollegeName) {
+		return fmt.Sprintf("include.colleges 不包含 %q", course.CollegeName)
+	}
+
+	for _, window := range []struct {
+		name   string
+		bound  string
+		value  string
+		before bool
+	}{
+		{"start_after", rules.StartAfter, course.StartDate, false},
+		{"start_before", rules.StartBefore, course.StartDate, true},
+		{"end_after", rules.EndAfter, course.EndDate, false},
+		{"end_before", rules.EndBefore, course.EndDate, true},
+	} {
+		if window.bound == "" {
+			continue
+		}
+		bound, err := time.ParseInLocation(config.DateLayout, window.bound, time.Local)
+		if err != nil {
+			return fmt.Sprintf("%s 日期格式错误", window.name)
+		}
+		date, ok := ParseDate(window.value)
+		if !ok {
+			return fmt.Sprintf("%s 无法识别课程日期 %q", window.name, window.value)
+		}
+		if window.before && date.After(bound) || !window.before && date.Before(bound) {
+			return fmt.Sprintf("%s %s, 课程为 %s", window.name, window.bound, date.Format(config.DateLayout))
+		}
+	}
+	return ""
+}
+
+// ParseDate 解析课程日期, 只取日期部分
+func ParseDate(text string) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) > len(config.DateLayout) {
+		text = text[:len(config.DateLayout)]
+	}
+	date, err := time.ParseInLocation(config.DateLayout, text, time.Local)
+	return date, err == nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, item := range ids {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsText(list []string, text string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == strings.TrimSpace(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName 返回第一个匹配课程名称的正则表达式
+func matchName(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(name) {
+			return pattern
+		}
+	}
+	return ""
+}
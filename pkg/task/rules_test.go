@@ -0,0 +1,54 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestExcludeReason(t *testing.T) {
+	course := types.CoursesList{ID: 7, Name: "大学英语(二)", CategoryName: "公共课", CollegeName: "外国语学院",
+		StartDate: "2024-03-01 00:00:00", EndDate: "2024-07-01 23:59:59"}
+	tests := []struct {
+		name  string
+		rules config.CourseRules
+		// want 排除原因中应包含的内容, 为空表示不排除
+		want string
+	}{
+		{name: "没有规则"},
+		{name: "包含课程ID", rules: config.CourseRules{Include: config.CourseMatch{IDs: []int{6, 7}}}},
+		{name: "不包含课程ID", rules: config.CourseRules{Include: config.CourseMatch{IDs: []int{6}}}, want: "include.ids"},
+		{name: "排除优先于包含", rules: config.CourseRules{
+			Include: config.CourseMatch{IDs: []int{7}},
+			Exclude: config.CourseMatch{Names: []string{"英语"}},
+		}, want: "exclude.names"},
+		{name: "排除课程ID", rules: config.CourseRules{Exclude: config.CourseMatch{IDs: []int{7}}}, want: "exclude.ids"},
+		{name: "名称正则锚点匹配", rules: config.CourseRules{Include: config.CourseMatch{Names: []string{`^大学英语`}}}},
+		{name: "名称正则锚点不匹配", rules: config.CourseRules{Include: config.CourseMatch{Names: []string{`^英语`}}}, want: "include.names"},
+		{name: "名称正则多选一", rules: config.CourseRules{Include: config.CourseMatch{Names: []string{`体育`, `英语\(二\)$`}}}},
+		{name: "错误的正则忽略", rules: config.CourseRules{Exclude: config.CourseMatch{Names: []string{`(`}}}},
+		{name: "错误的正则不算匹配", rules: config.CourseRules{Include: config.CourseMatch{Names: []string{`(`}}}, want: "include.names"},
+		{name: "排除分类", rules: config.CourseRules{Exclude: config.CourseMatch{Categories: []string{" 公共课 "}}}, want: "exclude.categories"},
+		{name: "包含的条件都要满足", rules: config.CourseRules{Include: config.CourseMatch{
+			Names: []string{"英语"}, Colleges: []string{"体育学院"},
+		}}, want: "include.colleges"},
+		{name: "开始日期之后", rules: config.CourseRules{StartAfter: "2024-02-01"}},
+		{name: "开始日期之前", rules: config.CourseRules{StartBefore: "2024-02-01"}, want: "start_before"},
+		{name: "结束日期当天", rules: config.CourseRules{EndBefore: "2024-07-01", EndAfter: "2024-07-01"}},
+		{name: "结束日期之后", rules: config.CourseRules{EndAfter: "2024-08-01"}, want: "end_after"},
+		{name: "日期格式错误", rules: config.CourseRules{EndAfter: "2024/08/01"}, want: "日期格式错误"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := ExcludeReason(config.User{Courses: test.rules}, course)
+			if test.want == "" && reason != "" {
+				t.Fatalf("不应排除, 原因为 %q", reason)
+			}
+			if !strings.Contains(reason, test.want) {
+				t.Fatalf("排除原因为 %q, 应包含 %q", reason, test.want)
+			}
+		})
+	}
+}
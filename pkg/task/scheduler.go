@@ -0,0 +1,320 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 优先级计算参数
+const (
+	// agingWeight 每被跳过一次增加的分值
+	agingWeight = 5
+	// noDeadline 没有结束日期的课程按一年后结束计算
+	noDeadline = 365 * 24 * time.Hour
+)
+
+// queued 等待调度的任务
+type queued struct {
+	task  Task
+	seq   int
+	skips int
+	// after 节点解锁前不调度
+	after time.Time
+}
+
+// scheduler 优先级调度器, 代替先进先出的任务队列
+// 结束时间越近、剩余视频越多的课程越先学习, 长时间排不上的任务逐渐提高优先级
+// 同时限制每个账号同时学习的课程数, 并优先调度正在学习课程数少的账号
+type scheduler struct {
+	sync.Mutex
+	cond   *sync.Cond
+	seq    int
+	items  []*queued
+	closed bool
+	// starve 被跳过的次数达到该值后直接排到最前
+	starve int
+	// running 每个账号正在学习的课程数, key为账号标识
+	running map[string]int
+	// wake 等待学习时段开始的定时器
+	wake   *time.Timer
+	wakeAt time.Time
+}
+
+func newScheduler(starve int) *scheduler {
+	s := &scheduler{starve: starve, running: map[string]int{}}
+	s.cond = sync.NewCond(&s.Mutex)
+	return s
+}
+
+// reset 清空上一次运行的队列, 开始新的运行
+func (s *scheduler) reset(starve int) {
+	s.Lock()
+	defer s.Unlock()
+	if s.wake != nil {
+		s.wake.Stop()
+		s.wake = nil
+	}
+	s.wakeAt = time.Time{}
+	s.seq = 0
+	s.items = nil
+	s.closed = false
+	s.starve = starve
+	s.running = map[string]int{}
+}
+
+// push 加入任务
+func (s *scheduler) push(task Task) {
+	s.pushAt(task, time.Time{})
+}
+
+// pushAt 加入任务, after 之前不调度
+func (s *scheduler) pushAt(task Task, after time.Time) {
+	s.Lock()
+	s.seq++
+	s.items = append(s.items, &queued{task: task, seq: s.seq, after: after})
+	s.Unlock()
+	s.cond.Signal()
+}
+
+// close 不再加入新任务, 队列取空且没有正在学习的任务后 pop 返回 false
+// 正在学习的任务到了学习时段结束或有节点等待解锁时会重新入队
+func (s *scheduler) close() {
+	s.Lock()
+	s.closed = true
+	s.Unlock()
+	s.cond.Broadcast()
+}
+
+// pop 取出账号并发未满的任务中优先级最高的一个, 没有可以调度的任务时等待
+// 取出的任务学习结束后需要调用 done 释放账号的并发名额
+func (s *scheduler) pop() (Task, bool) {
+	s.Lock()
+	defer s.Unlock()
+	for {
+		if len(s.items) == 0 && s.closed && s.idle() {
+			return Task{}, false
+		}
+		var next *queued
+		now := time.Now()
+		ranked := s.rank(now)
+		for _, item := range ranked {
+			if !item.after.After(now) && s.available(item.task.User, now) {
+				next = item
+				break
+			}
+		}
+		if next == nil {
+			s.sleepUntilWindow(now)
+			s.cond.Wait()
+			continue
+		}
+		for _, item := range ranked {
+			if item != next && !item.after.After(now) && s.available(item.task.User, now) {
+				item.skips++
+			}
+		}
+		for index, item := range s.items {
+			if item == next {
+				s.items = append(s.items[:index], s.items[index+1:]...)
+				break
+			}
+		}
+		s.running[next.task.User.Key()]++
+		return next.task, true
+	}
+}
+
+// done 任务结束, 释放账号的并发名额
+func (s *scheduler) done(task Task) {
+	s.Lock()
+	if s.running[task.User.Key()] > 0 {
+		s.running[task.User.Key()]--
+	}
+	s.Unlock()
+	s.cond.Broadcast()
+}
+
+// available 账号当前是否在学习时段内, 学校服务器可以访问, 并且还能再学习一门课程, 调用前需持有锁
+func (s *scheduler) available(user config.User, now time.Time) bool {
+	settings := config.EffectiveSettings(user)
+	if !settings.Schedule().Open(now) {
+		return false
+	}
+	// 服务器熔断期间不再安排该域名的任务
+	if b := breaker.Lookup(user.Host()); b != nil && b.Open() {
+		return false
+	}
+	return settings.MaxCourses <= 0 || s.running[user.Key()] < settings.MaxCourses
+}
+
+// idle 是否没有正在学习的任务, 调用前需持有锁
+func (s *scheduler) idle() bool {
+	for _, count := range s.running {
+		if count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sleepUntilWindow 排队的任务都不在学习时段内或等待节点解锁时, 在最近可以学习的时间唤醒等待的协程, 调用前需持有锁
+func (s *scheduler) sleepUntilWindow(now time.Time) {
+	var next time.Time
+	for _, item := range s.items {
+		open := config.EffectiveSettings(item.task.User).Schedule().Next(now)
+		if item.after.After(open) {
+			open = item.after
+		}
+		if open.After(now) && (next.IsZero() || open.Before(next)) {
+			next = open
+		}
+	}
+	if next.IsZero() || (s.wake != nil && s.wakeAt.After(now) && !s.wakeAt.After(next)) {
+		return
+	}
+	if s.wake != nil {
+		s.wake.Stop()
+	}
+	s.wakeAt = next
+	s.wake = time.AfterFunc(next.Sub(now), s.cond.Broadcast)
+	logrus.Infof("排队中的课程都不在学习时段内或等待节点解锁, %s 后继续", next.Sub(now).Round(time.Second))
+}
+
+// rank 按优先级从高到低排列, 调用前需持有锁
+func (s *scheduler) rank(now time.Time) []*queued {
+	return rankQueued(s.items, s.running, s.starve, now)
+}
+
+// rankQueued 按优先级从高到低排列
+// 排序依次为: 长时间排不上的任务、手动优先级、账号正在学习的课程数从少到多、自动计算的分值
+func rankQueued(items []*queued, running map[string]int, starve int, now time.Time) []*queued {
+	ranked := make([]*queued, len(items))
+	copy(ranked, items)
+	scores := map[*queued]float64{}
+	manual := map[*queued]int{}
+	for _, item := range ranked {
+		scores[item] = score(item, now)
+		manual[item] = Priority(item.task)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		starvedA, starvedB := starve > 0 && a.skips >= starve, starve > 0 && b.skips >= starve
+		if starvedA != starvedB {
+			return starvedA
+		}
+		if manual[a] != manual[b] {
+			return manual[a] > manual[b]
+		}
+		runningA, runningB := running[a.task.User.Key()], running[b.task.User.Key()]
+		if runningA != runningB {
+			return runningA < runningB
+		}
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return a.seq < b.seq
+	})
+	return ranked
+}
+
+// order 当前排队任务的调度顺序, key为任务标识
+// 复制队列后在锁外排序, 计算优先级时不会阻塞调度
+func (s *scheduler) order() map[string]int {
+	s.Lock()
+	items := make([]*queued, len(s.items))
+	for index, item := range s.items {
+		copied := *item
+		items[index] = &copied
+	}
+	running := make(map[string]int, len(s.running))
+	for key, count := range s.running {
+		running[key] = count
+	}
+	starve := s.starve
+	s.Unlock()
+
+	result := map[string]int{}
+	for index, item := range rankQueued(items, running, starve, time.Now()) {
+		result[item.task.Key()] = index
+	}
+	return result
+}
+
+// unlocks 等待节点解锁的任务及解锁时间, key为任务标识
+func (s *scheduler) unlocks() map[string]time.Time {
+	s.Lock()
+	defer s.Unlock()
+	result := map[string]time.Time{}
+	for _, item := range s.items {
+		if !item.after.IsZero() {
+			result[item.task.Key()] = item.after
+		}
+	}
+	return result
+}
+
+// score 自动计算的优先级分值
+// 剩余视频时长占剩余天数的比例 * 100 + 结束临近程度 * 10 + 被跳过次数 * 5
+func score(item *queued, now time.Time) float64 {
+	left := noDeadline
+	if end, ok := ParseDate(item.task.Course.EndDate); ok {
+		// 结束日期当天也可以学习
+		left = end.Add(24 * time.Hour).Sub(now)
+	}
+	if left < time.Hour {
+		left = time.Hour
+	}
+	pressure := float64(remainingOf(item.task)) / left.Seconds()
+	if pressure > 10 {
+		pressure = 10
+	}
+	proximity := 1 / (1 + left.Hours()/24)
+	return pressure*100 + proximity*10 + float64(item.skips)*agingWeight
+}
+
+// remainingOf 任务剩余学习时长, 还没有估算时使用入队时的估算值
+func remainingOf(task Task) int {
+	estimates.Lock()
+	defer estimates.Unlock()
+	if item, ok := estimates.data[task.Key()]; ok {
+		return item.remaining()
+	}
+	return task.Estimate
+}
+
+// 运行时手动设置的优先级, key为任务标识
+var priorities = struct {
+	sync.RWMutex
+	data map[string]int
+}{data: map[string]int{}}
+
+// Priority 任务的手动优先级, 运行时设置的优先于配置文件中 courses.priorities 的设置
+func Priority(task Task) int {
+	priorities.RLock()
+	value, ok := priorities.data[task.Key()]
+	priorities.RUnlock()
+	if ok {
+		return value
+	}
+	return task.User.Courses.Priorities[strconv.Itoa(task.Course.ID)]
+}
+
+// SetPriority 运行时调整任务的手动优先级, 数值越大越先学习, 只影响还在排队的任务
+func SetPriority(key string, priority int) bool {
+	events.RLock()
+	state, ok := events.states[key]
+	events.RUnlock()
+	if !ok {
+		return false
+	}
+	priorities.Lock()
+	priorities.data[key] = priority
+	priorities.Unlock()
+	emit(state.task, state.Status, state.Progress, state.Message, nil)
+	return true
+}
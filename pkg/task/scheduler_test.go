@@ -0,0 +1,162 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+// schedulerTask 调度测试用的任务, days 为距离结束的天数, 为0时没有结束日期
+func schedulerTask(user config.User, id int, days int, estimate int) Task {
+	course := types.CoursesList{ID: id, Name: "课程"}
+	if days > 0 {
+		course.EndDate = time.Now().AddDate(0, 0, days).Format(config.DateLayout)
+	}
+	return Task{User: user, Course: course, Estimate: estimate}
+}
+
+func TestRankQueued(t *testing.T) {
+	alice := config.User{Username: "alice", BaseURL: "https://rank.example.com"}
+	bob := config.User{Username: "bob", BaseURL: "https://rank.example.com"}
+	pinned := alice
+	pinned.Courses.Priorities = map[string]int{"3": 10}
+	tests := []struct {
+		name    string
+		items   []*queued
+		running map[string]int
+		starve  int
+		want    []int
+	}{
+		{
+			name: "结束时间近的优先于剩余时长多的",
+			items: []*queued{
+				{task: schedulerTask(alice, 1, 300, 36000), seq: 1},
+				{task: schedulerTask(alice, 2, 1, 600), seq: 2},
+			},
+			want: []int{2, 1},
+		},
+		{
+			name: "结束时间相同时剩余时长多的优先",
+			items: []*queued{
+				{task: schedulerTask(alice, 1, 30, 600), seq: 1},
+				{task: schedulerTask(alice, 2, 30, 36000), seq: 2},
+			},
+			want: []int{2, 1},
+		},
+		{
+			name: "手动优先级高于自动计算的分值",
+			items: []*queued{
+				{task: schedulerTask(pinned, 2, 1, 36000), seq: 1},
+				{task: schedulerTask(pinned, 3, 0, 60), seq: 2},
+			},
+			want: []int{3, 2},
+		},
+		{
+			name: "长时间排不上的任务排到最前",
+			items: []*queued{
+				{task: schedulerTask(pinned, 3, 1, 36000), seq: 1},
+				{task: schedulerTask(alice, 4, 0, 60), seq: 2, skips: 3},
+			},
+			starve: 3,
+			want:   []int{4, 3},
+		},
+		{
+			name: "正在学习课程数少的账号优先",
+			items: []*queued{
+				{task: schedulerTask(alice, 1, 1, 36000), seq: 1},
+				{task: schedulerTask(bob, 2, 0, 60), seq: 2},
+			},
+			running: map[string]int{alice.Key(): 2, bob.Key(): 1},
+			want:    []int{2, 1},
+		},
+		{
+			name: "分值相同时先入队的优先",
+			items: []*queued{
+				{task: schedulerTask(alice, 1, 0, 600), seq: 2},
+				{task: schedulerTask(alice, 2, 0, 600), seq: 1},
+			},
+			want: []int{2, 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranked := rankQueued(test.items, test.running, test.starve, time.Now())
+			for index, item := range ranked {
+				if item.task.Course.ID != test.want[index] {
+					t.Fatalf("第 %d 个为课程 %d, 应为 %v", index+1, item.task.Course.ID, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPopPromotesStarvingTask(t *testing.T) {
+	useTempDir(t)
+	config.Conf = config.Config{}
+	user := config.User{Username: "carol", BaseURL: "https://starve.example.com"}
+	user.Courses.Priorities = map[string]int{"1": 10, "2": 10, "3": 10, "4": 10}
+	s := newScheduler(2)
+	s.push(schedulerTask(user, 9, 0, 60))
+
+	// 不断有高优先级的课程入队, 低优先级的课程被跳过2次后排到最前
+	var order []int
+	for id := 1; id <= 4; id++ {
+		s.push(schedulerTask(user, id, 0, 60))
+		task, ok := s.pop()
+		if !ok {
+			t.Fatal("队列不应提前结束")
+		}
+		order = append(order, task.Course.ID)
+		s.done(task)
+	}
+	want := []int{1, 2, 9, 3}
+	for index := range want {
+		if order[index] != want[index] {
+			t.Fatalf("调度顺序为 %v, 应为 %v", order, want)
+		}
+	}
+}
+
+func TestPopRespectsMaxCourses(t *testing.T) {
+	useTempDir(t)
+	config.Conf = config.Config{}
+	limited := config.User{Username: "dave", BaseURL: "https://cap.example.com",
+		Settings: config.Settings{MaxCourses: 1}}
+	limited.Courses.Priorities = map[string]int{"1": 10, "2": 10}
+	other := config.User{Username: "erin", BaseURL: "https://cap.example.com"}
+	s := newScheduler(0)
+	s.push(schedulerTask(limited, 1, 0, 60))
+	s.push(schedulerTask(limited, 2, 0, 60))
+	s.push(schedulerTask(other, 3, 0, 60))
+
+	first, _ := s.pop()
+	if first.Course.ID != 1 {
+		t.Fatalf("应先调度优先级高的课程1, 得到 %d", first.Course.ID)
+	}
+	second, _ := s.pop()
+	if second.Course.ID != 3 {
+		t.Fatalf("账号达到同时学习的课程数上限后应调度其他账号的课程, 得到 %d", second.Course.ID)
+	}
+
+	popped := make(chan Task, 1)
+	go func() {
+		task, _ := s.pop()
+		popped <- task
+	}()
+	select {
+	case task := <-popped:
+		t.Fatalf("账号的课程结束前不应调度课程 %d", task.Course.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.done(first)
+	select {
+	case task := <-popped:
+		if task.Course.ID != 2 {
+			t.Fatalf("课程结束后应调度同一账号的课程2, 得到 %d", task.Course.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("课程结束后没有释放账号的名额")
+	}
+}
@@ -0,0 +1,294 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status 任务生命周期状态, JSON中为英文标识, 显示时使用中文名称
+type Status string
+
+// 任务状态
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusPaused  Status = "paused"
+	StatusWaiting Status = "waiting-unlock"
+	StatusSkipped Status = "skipped"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+var statusNames = map[Status]string{
+	StatusPending: "等待中",
+	StatusRunning: "进行中",
+	StatusPaused:  "已暂停",
+	StatusWaiting: "等待解锁",
+	StatusSkipped: "已跳过",
+	StatusDone:    "已完成",
+	StatusFailed:  "失败",
+}
+
+// String 状态的中文名称
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return string(s)
+}
+
+// Finished 任务是否已经结束, 不会再被调度
+func (s Status) Finished() bool {
+	return s == StatusDone || s == StatusSkipped || s == StatusFailed
+}
+
+// historySize 每个任务保留的状态变更记录条数
+const historySize = 50
+
+// Transition 状态变更记录
+type Transition struct {
+	From   Status    `json:"from,omitempty"`
+	To     Status    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Event 任务状态变更事件
+type Event struct {
+	Task Task
+	// From 状态变更前的状态, 状态没有变化时与 Status 相同
+	From      Status
+	Status    Status
+	Progress  float64
+	Message   string
+	Err       error
+	Remaining int
+	ETA       time.Time
+	// Skip 节点被跳过时附带的跳过记录
+	Skip *Skip
+	// Todos 课程中需要手动完成的非视频节点
+	Todos []todo.Item
+	Time  time.Time
+}
+
+// Skip 被跳过的节点和原因
+type Skip struct {
+	NodeID int    `json:"node_id"`
+	Node   string `json:"node"`
+	Reason string `json:"reason"`
+	// Until 按时间解锁的节点的解锁时间
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// State 任务当前状态, 供状态查询和web端使用
+type State struct {
+	Key      string  `json:"key"`
+	User     string  `json:"user"`
+	CourseID int     `json:"course_id"`
+	Course   string  `json:"course"`
+	Status   Status  `json:"status"`
+	Progress float64 `json:"progress"`
+	Message  string  `json:"message,omitempty"`
+	// Since 进入当前状态的时间
+	Since time.Time `json:"since"`
+	// History 状态变更记录, 最早的在前
+	History   []Transition `json:"history,omitempty"`
+	Remaining int          `json:"remaining"`
+	ETA       time.Time    `json:"eta"`
+	Priority  int          `json:"priority"`
+	// NextWindow 等待中的任务不在学习时段内时, 下一个学习时段的开始时间
+	NextWindow time.Time `json:"next_window,omitempty"`
+	// Verification 学习结束后与平台核对的结果
+	Verification *Verification `json:"verification,omitempty"`
+	// Skips 被跳过的节点
+	Skips []Skip `json:"skips,omitempty"`
+	// Todos 需要手动完成的非视频节点
+	Todos     []todo.Item `json:"todos,omitempty"`
+	StartedAt time.Time   `json:"started_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	task      Task
+}
+
+// belongsTo 任务是否属于该账号, user 为账号标识或用户名, 为空时属于全部账号
+func (s *State) belongsTo(user string) bool {
+	return user == "" || s.User == user || s.task.User.Username == user
+}
+
+func (s *State) event() Event {
+	return Event{
+		Task:      s.task,
+		From:      s.Status,
+		Status:    s.Status,
+		Progress:  s.Progress,
+		Message:   s.Message,
+		Remaining: s.Remaining,
+		ETA:       s.ETA,
+		Todos:     s.Todos,
+		Time:      s.UpdatedAt,
+	}
+}
+
+var events = struct {
+	sync.RWMutex
+	listeners []func(Event)
+	states    map[string]*State
+}{states: map[string]*State{}}
+
+// Subscribe 订阅任务状态变更
+func Subscribe(listener func(Event)) {
+	events.Lock()
+	defer events.Unlock()
+	events.listeners = append(events.listeners, listener)
+}
+
+// States 全部任务的当前状态
+func States() []State {
+	events.RLock()
+	defer events.RUnlock()
+	now := time.Now()
+	states := make([]State, 0, len(events.states))
+	for _, state := range events.states {
+		item := *state
+		item.Skips = append([]Skip(nil), state.Skips...)
+		item.Todos = append([]todo.Item(nil), state.Todos...)
+		item.History = append([]Transition(nil), state.History...)
+		if item.Status == StatusPending || item.Status == StatusPaused {
+			if next := config.EffectiveSettings(item.task.User).Schedule().Next(now); next.After(now) {
+				item.NextWindow = next
+			}
+		}
+		states = append(states, item)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].User != states[j].User {
+			return states[i].User < states[j].User
+		}
+		return states[i].CourseID < states[j].CourseID
+	})
+	return states
+}
+
+// emit 更新任务状态, 重新估算剩余时间并通知订阅者
+// 状态变化时记录变更原因和时间
+func emit(task Task, status Status, progress float64, message string, err error) {
+	now := time.Now()
+	if err != nil && message == "" {
+		message = err.Error()
+	}
+
+	events.Lock()
+	state, ok := events.states[task.Key()]
+	if !ok {
+		state = &State{
+			Key:       task.Key(),
+			User:      task.User.Key(),
+			CourseID:  task.Course.ID,
+			Course:    task.Course.Name,
+			StartedAt: now,
+			task:      task,
+		}
+		events.states[task.Key()] = state
+	}
+	from := state.Status
+	if from != status {
+		state.History = append(state.History, Transition{From: from, To: status, Reason: message, Time: now})
+		if len(state.History) > historySize {
+			state.History = state.History[len(state.History)-historySize:]
+		}
+		state.Since = now
+	}
+	state.Status = status
+	state.Progress = progress
+	state.Message = message
+	state.Priority = Priority(task)
+	state.UpdatedAt = now
+	events.Unlock()
+
+	trackStatus(task, status)
+	publish(task, from, err)
+}
+
+// recordSkip 记录被跳过的节点并通知订阅者, 同一节点只保留最新的原因
+func recordSkip(task Task, skip Skip) {
+	events.Lock()
+	state, ok := events.states[task.Key()]
+	if !ok {
+		events.Unlock()
+		return
+	}
+	replaced := false
+	for index := range state.Skips {
+		if state.Skips[index].NodeID == skip.NodeID {
+			state.Skips[index] = skip
+			replaced = true
+		}
+	}
+	if !replaced {
+		state.Skips = append(state.Skips, skip)
+	}
+	event := state.event()
+	event.Skip = &skip
+	listeners := events.listeners
+	events.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// recordVerification 记录核对结果, 随下一次状态变更通知订阅者
+func recordVerification(task Task, verification Verification) {
+	events.Lock()
+	defer events.Unlock()
+	if state, ok := events.states[task.Key()]; ok {
+		state.Verification = &verification
+	}
+}
+
+// recordTodos 记录课程的待办事项, 随下一次状态变更通知订阅者
+func recordTodos(task Task, items []todo.Item) {
+	events.Lock()
+	defer events.Unlock()
+	if state, ok := events.states[task.Key()]; ok {
+		state.Todos = items
+	}
+}
+
+// updateCourse 保存重新获取的课程信息, 供进度报告使用
+func updateCourse(task Task, course types.CoursesList) {
+	events.Lock()
+	defer events.Unlock()
+	if state, ok := events.states[task.Key()]; ok {
+		state.task.Course = course
+	}
+}
+
+// clearSkip 节点解锁并学完后删除跳过记录
+func clearSkip(task Task, nodeID int) {
+	events.Lock()
+	defer events.Unlock()
+	state, ok := events.states[task.Key()]
+	if !ok {
+		return
+	}
+	for index := range state.Skips {
+		if state.Skips[index].NodeID == nodeID {
+			state.Skips = append(state.Skips[:index], state.Skips[index+1:]...)
+			return
+		}
+	}
+}
+
+// skipCount 任务被跳过的节点数
+func skipCount(task Task) int {
+	events.RLock()
+	defer events.RUnlock()
+	if state, ok := events.states[task.Key()]; ok {
+		return len(state.Skips)
+	}
+	return 0
+}
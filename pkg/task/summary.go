@@ -0,0 +1,267 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// CourseSummary 单门课程的运行结果
+type CourseSummary struct {
+	Key      string `json:"key"`
+	CourseID int    `json:"course_id"`
+	Course   string `json:"course"`
+	Status   Status `json:"status"`
+	Message  string `json:"message,omitempty"`
+	// ProgressBefore 开始学习前平台记录的进度, ProgressAfter 结束时的进度
+	ProgressBefore float64       `json:"progress_before"`
+	ProgressAfter  float64       `json:"progress_after"`
+	NodesCompleted int           `json:"nodes_completed"`
+	Skips          []Skip        `json:"skips,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Verification   *Verification `json:"verification,omitempty"`
+	Retries        int64         `json:"retries"`
+	// StudySeconds 实际学习的时间, 不含排队和等待, 秒
+	StudySeconds int `json:"study_seconds"`
+}
+
+// UserSummary 单个账号的运行结果
+type UserSummary struct {
+	User           string          `json:"user"`
+	Courses        []CourseSummary `json:"courses"`
+	NodesCompleted int             `json:"nodes_completed"`
+	Skipped        int             `json:"skipped"`
+	Failed         int             `json:"failed"`
+	Retries        int64           `json:"retries"`
+	StudySeconds   int             `json:"study_seconds"`
+}
+
+// Summary 一次运行的结果
+type Summary struct {
+	// RunID 本进程中第几次运行, 从1开始
+	RunID      int       `json:"run_id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	// WallSeconds 从启动到结束的时间, 秒
+	WallSeconds int           `json:"wall_seconds"`
+	Finished    bool          `json:"finished"`
+	Users       []UserSummary `json:"users"`
+}
+
+// runStat 任务在本次运行中的统计, 多次调度累加
+type runStat struct {
+	nodes   int
+	retries int64
+	study   time.Duration
+}
+
+var runs = struct {
+	sync.Mutex
+	// id 当前运行的编号, 统计只记录属于当前运行的任务
+	id         int
+	startedAt  time.Time
+	finishedAt time.Time
+	stats      map[string]*runStat
+	last       *Summary
+}{stats: map[string]*runStat{}}
+
+// recordRun 累加一次调度的学习时间和重试次数
+func recordRun(task Task, started time.Time, retries int64) {
+	runs.Lock()
+	defer runs.Unlock()
+	stat := runStatOf(task)
+	if stat == nil {
+		return
+	}
+	stat.study += time.Since(started)
+	stat.retries += retries
+}
+
+// recordNode 累加学完的节点数
+func recordNode(task Task) {
+	runs.Lock()
+	defer runs.Unlock()
+	if stat := runStatOf(task); stat != nil {
+		stat.nodes++
+	}
+}
+
+// runStatOf 任务在当前运行中的统计, 不属于当前运行的任务返回nil, 调用前需持有 runs 的锁
+func runStatOf(task Task) *runStat {
+	if task.run != runs.id {
+		return nil
+	}
+	stat, ok := runs.stats[task.Key()]
+	if !ok {
+		stat = &runStat{}
+		runs.stats[task.Key()] = stat
+	}
+	return stat
+}
+
+// Summarize 按当前状态生成运行结果, 运行中也可以调用
+func Summarize() Summary {
+	states := States()
+	runs.Lock()
+	summary := Summary{RunID: runs.id, StartedAt: runs.startedAt, FinishedAt: runs.finishedAt, Finished: !runs.finishedAt.IsZero()}
+	stats := map[string]runStat{}
+	for key, stat := range runs.stats {
+		stats[key] = *stat
+	}
+	runs.Unlock()
+
+	end := summary.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !summary.StartedAt.IsZero() {
+		summary.WallSeconds = int(end.Sub(summary.StartedAt).Seconds())
+	}
+
+	users := map[string]*UserSummary{}
+	var order []string
+	for _, state := range states {
+		if state.task.run != summary.RunID {
+			continue
+		}
+		user, ok := users[state.User]
+		if !ok {
+			user = &UserSummary{User: state.User}
+			users[state.User] = user
+			order = append(order, state.User)
+		}
+		stat := stats[state.Key]
+		course := CourseSummary{
+			Key:            state.Key,
+			CourseID:       state.CourseID,
+			Course:         state.Course,
+			Status:         state.Status,
+			Message:        state.Message,
+			ProgressBefore: float64(state.task.Course.Progress),
+			ProgressAfter:  state.Progress,
+			NodesCompleted: stat.nodes,
+			Skips:          state.Skips,
+			Verification:   state.Verification,
+			Retries:        stat.retries,
+			StudySeconds:   int(stat.study.Seconds()),
+		}
+		if state.Status == StatusFailed {
+			course.Error = state.Message
+		}
+		user.Courses = append(user.Courses, course)
+		user.NodesCompleted += course.NodesCompleted
+		user.Skipped += len(course.Skips)
+		user.Retries += course.Retries
+		user.StudySeconds += course.StudySeconds
+		if course.Status == StatusFailed {
+			user.Failed++
+		}
+	}
+	sort.Strings(order)
+	for _, key := range order {
+		summary.Users = append(summary.Users, *users[key])
+	}
+	return summary
+}
+
+// LastSummary 最近一次运行结束时的结果, 还没有运行结束时返回 false
+func LastSummary() (Summary, bool) {
+	runs.Lock()
+	defer runs.Unlock()
+	if runs.last == nil {
+		return Summary{}, false
+	}
+	return *runs.last, true
+}
+
+// finishRun 全部任务结束后生成运行结果, 输出到日志并保存为JSON
+func finishRun() Summary {
+	runs.Lock()
+	runs.finishedAt = time.Now()
+	runs.Unlock()
+
+	summary := Summarize()
+	runs.Lock()
+	runs.last = &summary
+	runs.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(summary.Table(), "\n"), "\n") {
+		logrus.Info(line)
+	}
+	path, err := summary.Save()
+	if err != nil {
+		logrus.Errorf("保存运行报告失败: %s", err.Error())
+	} else {
+		logrus.Infof("运行报告已保存到 %s", path)
+	}
+	return summary
+}
+
+// Save 保存为 reports/summary-时间-run编号.json
+// 同一秒开始的运行(如命令行和GUI同时运行)文件名相同时加上序号, 不会覆盖其他运行的结果
+func (s Summary) Save() (string, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(config.ReportDir, 0755); err != nil {
+		return "", err
+	}
+	base := fmt.Sprintf("summary-%s-run%d", s.StartedAt.Format("20060102-150405"), s.RunID)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		path := filepath.Join(config.ReportDir, name+".json")
+		// 先独占创建文件占用文件名, 再原子写入内容
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_ = file.Close()
+		return path, util.WriteFileAtomic(path, data, 0644, 0)
+	}
+}
+
+// Table 以表格形式输出运行结果, 跳过的节点和错误列在表格后面
+func (s Summary) Table() string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "运行结果: 用时 %s, 开始于 %s\n", util.FormatSeconds(s.WallSeconds), s.StartedAt.Format("01-02 15:04:05"))
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "账号\t课程\t状态\t进度\t学完节点\t跳过\t重试\t学习时间\t说明")
+	var details []string
+	for _, user := range s.Users {
+		for _, course := range user.Courses {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%.0f%% -> %.0f%%\t%d\t%d\t%d\t%s\t%s\n", user.User, course.Course, course.Status,
+				course.ProgressBefore*100, course.ProgressAfter*100, course.NodesCompleted, len(course.Skips),
+				course.Retries, util.FormatSeconds(course.StudySeconds), course.Message)
+			for _, skip := range course.Skips {
+				details = append(details, fmt.Sprintf("[%s] 课程[%s] 跳过[%s]: %s", user.User, course.Course, skip.Node, skip.Reason))
+			}
+			if course.Error != "" {
+				details = append(details, fmt.Sprintf("[%s] 课程[%s] 失败: %s", user.User, course.Course, course.Error))
+			}
+		}
+		fmt.Fprintf(writer, "%s\t合计\t\t\t%d\t%d\t%d\t%s\t失败 %d\n", user.User, user.NodesCompleted, user.Skipped,
+			user.Retries, util.FormatSeconds(user.StudySeconds), user.Failed)
+	}
+	_ = writer.Flush()
+	for _, line := range details {
+		buffer.WriteString(line + "\n")
+	}
+	return buffer.String()
+}
@@ -0,0 +1,175 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+// fakePlatform 模拟学习平台, 每门课程有两个视频节点, 查询一次进度后完成
+type fakePlatform struct {
+	sync.Mutex
+	courses  []int
+	finished map[int]bool
+	// lost 提交进度时返回已完成但没有记录的次数, 模拟平台进度与节点状态不一致
+	lost map[int]int
+	// videos 每个节点提交进度的次数
+	videos map[int]int
+}
+
+func (p *fakePlatform) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	_ = request.ParseForm()
+	p.Lock()
+	defer p.Unlock()
+	var result interface{}
+	switch request.URL.Path {
+	case "/api/login.json":
+		result = map[string]interface{}{"data": map[string]interface{}{"token": "token"}}
+	case "/api/course.json":
+		var list []map[string]interface{}
+		for _, id := range p.courses {
+			list = append(list, map[string]interface{}{"id": id, "name": fmt.Sprintf("课程%d", id), "state": 1,
+				"endDate": "2099-01-01", "videoCount": 2})
+		}
+		result = map[string]interface{}{"list": list}
+	case "/api/course/chapter.json":
+		courseID, _ := strconv.Atoi(request.FormValue("courseId"))
+		var nodes []map[string]interface{}
+		for index := 1; index <= 2; index++ {
+			id := courseID*10 + index
+			state := 0
+			if p.finished[id] {
+				state = 2
+			}
+			nodes = append(nodes, map[string]interface{}{"id": id, "name": fmt.Sprintf("第%d节", index), "idx": index,
+				"tabVideo": true, "videoDuration": "60", "videoState": state})
+		}
+		result = map[string]interface{}{"list": []map[string]interface{}{{"id": courseID, "name": "第一章", "idx": 1, "nodeList": nodes}}}
+	case "/api/node/study.json":
+		result = map[string]interface{}{"data": map[string]interface{}{"studyId": 1}}
+	case "/api/node/video.json":
+		id, _ := strconv.Atoi(request.FormValue("nodeId"))
+		if p.videos != nil {
+			p.videos[id]++
+		}
+		if p.lost[id] > 0 {
+			p.lost[id]--
+		} else {
+			p.finished[id] = true
+		}
+		result = map[string]interface{}{"data": map[string]interface{}{"videoDuration": 60,
+			"study_total": map[string]interface{}{"duration": "60", "progress": "1.00", "state": "2"}}}
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(map[string]interface{}{"_code": 0, "msg": "提交成功", "result": result})
+}
+
+// useTempDir 在临时目录中运行, 测试结束后恢复工作目录和配置
+func useTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Conf
+	t.Cleanup(func() {
+		config.Conf = old
+		_ = os.Chdir(wd)
+	})
+}
+
+// useFakePlatform 在临时目录中使用模拟平台运行, 测试结束后恢复
+func useFakePlatform(t *testing.T, platform *fakePlatform) config.User {
+	t.Helper()
+	server := httptest.NewServer(platform)
+	t.Cleanup(server.Close)
+	useTempDir(t)
+	user := config.User{Username: "runner", Password: "pw", BaseURL: server.URL}
+	config.Conf = config.Config{
+		Global: config.Global{Limit: 2, Settings: config.Settings{PollInterval: 1, BreakerThreshold: -1, HistoryInterval: -1}},
+		Users:  []config.User{user},
+	}
+	return user
+}
+
+func TestRunTwiceScopesSummary(t *testing.T) {
+	platform := &fakePlatform{courses: []int{1}, finished: map[int]bool{}}
+	user := useFakePlatform(t, platform)
+
+	AddTask(Task{User: user, Course: types.CoursesList{ID: 1, Name: "课程1", State: 1, EndDate: "2099-01-01"}})
+	Start()
+	first, ok := LastSummary()
+	if !ok || len(first.Users) != 1 || len(first.Users[0].Courses) != 1 {
+		t.Fatalf("第一次运行的结果不正确: %+v", first)
+	}
+	if course := first.Users[0].Courses[0]; course.CourseID != 1 || course.Status != StatusDone || course.NodesCompleted != 2 {
+		t.Fatalf("第一次运行的课程结果不正确: %+v", course)
+	}
+
+	platform.Lock()
+	platform.courses = []int{2}
+	platform.Unlock()
+	AddTask(Task{User: user, Course: types.CoursesList{ID: 2, Name: "课程2", State: 1, EndDate: "2099-01-01"}})
+	Start()
+	second, ok := LastSummary()
+	if !ok || second.RunID != first.RunID+1 {
+		t.Fatalf("第二次运行的编号应为 %d, 得到 %+v", first.RunID+1, second)
+	}
+	if len(second.Users) != 1 || len(second.Users[0].Courses) != 1 || second.Users[0].Courses[0].CourseID != 2 {
+		t.Fatalf("第二次运行的结果不应包括上一次的课程: %+v", second.Users)
+	}
+	if nodes := second.Users[0].NodesCompleted; nodes != 2 {
+		t.Fatalf("第二次运行学完的节点应为2, 得到 %d", nodes)
+	}
+
+	// 上一次运行的任务晚到的统计不计入当前运行
+	recordNode(Task{User: user, Course: types.CoursesList{ID: 2}, run: first.RunID})
+	recordRun(Task{User: user, Course: types.CoursesList{ID: 2}, run: first.RunID}, second.StartedAt, 3)
+	current := Summarize()
+	if current.RunID != second.RunID || current.Users[0].NodesCompleted != 2 || current.Users[0].Retries != second.Users[0].Retries {
+		t.Fatalf("上一次运行的统计不应计入当前运行: %+v", current.Users)
+	}
+}
+
+func TestSaveSummaryDoesNotOverwrite(t *testing.T) {
+	useTempDir(t)
+	started := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	var paths []string
+	for index := 0; index < 3; index++ {
+		// 同一秒开始的运行, 如命令行和GUI同时运行时编号都为1
+		summary := Summary{RunID: 1, StartedAt: started, WallSeconds: index}
+		path, err := summary.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	for index, path := range paths {
+		var saved Summary
+		if err := util.LoadJson(path, &saved); err != nil {
+			t.Fatal(err)
+		}
+		if saved.WallSeconds != index {
+			t.Fatalf("%s 保存的是第 %d 次运行的结果, 应为第 %d 次", path, saved.WallSeconds+1, index+1)
+		}
+	}
+	if paths[0] == paths[1] || paths[1] == paths[2] {
+		t.Fatalf("同一秒开始的运行应保存为不同的文件: %v", paths)
+	}
+}
@@ -1,68 +1,288 @@
 package task
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/history"
+	"github.com/aoaostar/mooc/pkg/todo"
 	"github.com/aoaostar/mooc/pkg/yinghua"
 	"github.com/aoaostar/mooc/pkg/yinghua/types"
 	"github.com/sirupsen/logrus"
 	"math"
 	"sync"
+	"time"
 )
 
 type Task struct {
 	User   config.User
 	Course types.CoursesList
-	Status bool
+	// Estimate 入队时估算的剩余学习时长, 秒
+	Estimate int
+	// run 所属运行的编号, 开始运行时设置
+	run int
 }
 
+// Key 任务唯一标识, 由账号标识和课程ID组成, 多次运行保持不变
+func (t Task) Key() string {
+	return fmt.Sprintf("%s#%d", t.User.Key(), t.Course.ID)
+}
+
+// Tasks 下一次运行要学习的任务, 通过 AddTask 添加, 开始运行时取出并清空
 var Tasks []Task
 
+// tasksLock 保护 Tasks
+var tasksLock sync.Mutex
+
+// queue 当前的任务调度器
+var queue = newScheduler(0)
+
+// AddTask 添加下一次运行要学习的任务
+func AddTask(task Task) {
+	tasksLock.Lock()
+	defer tasksLock.Unlock()
+	Tasks = append(Tasks, task)
+}
+
+// workers 协程数, 不超过任务数
+func workers(tasks int) int {
+	return int(math.Min(float64(config.Conf.Global.Limit), float64(tasks)))
+}
+
+// startRun 取出本次运行的任务, 并清空上一次运行的全部状态
+// 断点和进度历史需要跨运行保留, 不在这里清空
+func startRun() []Task {
+	tasksLock.Lock()
+	tasks := Tasks
+	Tasks = nil
+	tasksLock.Unlock()
+
+	// 被跳过的次数超过协程数的3倍后直接调度, 避免一直排不上
+	queue.reset(workers(len(tasks)) * 3)
+
+	events.Lock()
+	events.states = map[string]*State{}
+	events.Unlock()
+
+	priorities.Lock()
+	priorities.data = map[string]int{}
+	priorities.Unlock()
+
+	estimates.Lock()
+	estimates.seq = 0
+	estimates.data = map[string]*estimate{}
+	estimates.Unlock()
+
+	verifyRounds.Lock()
+	verifyRounds.data = map[string]int{}
+	verifyRounds.Unlock()
+
+	breakerPaused.Lock()
+	breakerPaused.data = map[string]Task{}
+	breakerPaused.Unlock()
+
+	historyProgress.Lock()
+	historyProgress.data = map[string]*studyingNodes{}
+	historyProgress.Unlock()
+
+	runs.Lock()
+	runs.id++
+	runs.startedAt = time.Now()
+	runs.finishedAt = time.Time{}
+	runs.stats = map[string]*runStat{}
+	for index := range tasks {
+		tasks[index].run = runs.id
+	}
+	runs.Unlock()
+	return tasks
+}
+
 func Start() {
-	limit := int(math.Min(float64(config.Conf.Global.Limit), float64(len(Tasks))))
-	jobs := make(chan Task, limit)
+	tasks := startRun()
+	stopHistory := startHistory(tasks)
+	limit := workers(len(tasks))
+	for _, task := range tasks {
+		emit(task, StatusPending, float64(task.Course.Progress), "", nil)
+		queue.push(task)
+	}
+	queue.close()
+
 	wg := sync.WaitGroup{}
 	for i := 0; i < limit; i++ {
 		go func() {
 			defer wg.Done()
-			for job := range jobs {
+			for {
+				job, ok := queue.pop()
+				if !ok {
+					return
+				}
 				work(job)
+				queue.done(job)
 			}
 		}()
 		wg.Add(1)
 	}
 
-	logrus.Infof("任务系统启动成功, 协程数: %d, 任务数: %d", limit, len(Tasks))
-
-	for _, task := range Tasks {
-		jobs <- task
-	}
-	close(jobs)
+	logrus.Infof("任务系统启动成功, 协程数: %d, 任务数: %d", limit, len(tasks))
 	wg.Wait()
+	if err := FlushCheckpoints(); err != nil {
+		logrus.Warnf("保存断点失败: %s", err.Error())
+	}
+	logVerifications()
+	logTodos()
+	finishRun()
+	stopHistory()
+	closeHistory()
 	logrus.Infof("恭喜您, 所有任务都已全部完成~~~")
 }
 
+// SkipReason 课程无需学习的原因, 需要学习时返回空字符串
+func SkipReason(course types.CoursesList) string {
+	if course.Progress == 1 {
+		return "课程已完成"
+	}
+	if course.State == 2 {
+		return "课程已结束"
+	}
+	return ""
+}
+
 func work(task Task) {
 	instance := yinghua.New(task.User)
-	err := instance.Login()
+	defer func(started time.Time) {
+		recordRun(task, started, instance.Retries())
+	}(time.Now())
+	err := instance.Resume()
 	if err != nil {
-		logrus.Fatal(err)
+		instance.OutputWith(fmt.Sprintf("登录失败: %s", err.Error()), logrus.Errorf)
+		emit(task, StatusFailed, float64(task.Course.Progress), "登录失败", err)
+		return
 	}
 
 	instance.Output("登录成功")
-
-	if task.Course.Progress == 1 {
-		instance.Output(fmt.Sprintf("当前课程[%s][%d] 进度: %s, 跳过", task.Course.Name, task.Course.ID, task.Course.Progress1))
-		return
+	recordHistory(task, task.Course, nil)
+	instance.OnChapters = func(chapters []types.ChaptersList) {
+		trackChapters(task, chapters)
+		recordHistoryNodes(task, history.NewNodes(chapters)...)
+		recordTodos(task, todo.Collect(task.User.Key(), task.Course, chapters, time.Now()))
+	}
+	instance.OnProgress = func(node types.ChaptersNodeList, progress types.NodeVideoData) {
+		trackProgress(task, node.ID, progress.RemainingSeconds())
+		recordHistoryProgress(task, node, progress)
+	}
+	// 从断点继续, 已完成的节点不再学习
+	done := doneNodes(task)
+	instance.SkipNode = func(node types.ChaptersNodeList) bool {
+		return done[node.ID]
+	}
+	instance.OnSkip = func(skip yinghua.NodeSkip) {
+		item := Skip{NodeID: skip.Node.ID, Node: skip.Node.Name, Reason: skip.Reason}
+		if !skip.Until.IsZero() {
+			item.Until = &skip.Until
+		}
+		recordSkip(task, item)
 	}
-	if task.Course.State == 2 {
-		instance.Output(fmt.Sprintf("当前课程[%s][%d] 已结束, 跳过", task.Course.Name, task.Course.ID))
+	instance.NodeDone = func(node types.ChaptersNodeList) {
+		clearSkip(task, node.ID)
+		// 开始前就已经完成的节点不计入本次学完的节点
+		if node.VideoState != 2 {
+			recordNode(task)
+		}
+		trackProgress(task, node.ID, 0)
+		finished := history.NewNode("", node)
+		finished.VideoState, finished.Studied = 2, node.VideoSeconds()
+		recordHistoryNodes(task, finished)
+		done[node.ID] = true
+		if err := checkpointNode(task, node.ID); err != nil {
+			instance.OutputWith(fmt.Sprintf("保存断点失败: %s", err.Error()), logrus.Warnf)
+		}
+	}
+
+	if reason := SkipReason(task.Course); reason != "" {
+		// 视频已经学完的课程仍然可能有需要手动完成的作业和考试
+		if !todo.Ended(task.Course) {
+			if chapters, err := instance.GetChapters(task.Course); err == nil {
+				recordTodos(task, todo.Collect(task.User.Key(), task.Course, chapters, time.Now()))
+			}
+		}
+		instance.Output(fmt.Sprintf("当前课程[%s][%d] 进度: %s, %s, 跳过", task.Course.Name, task.Course.ID, task.Course.Progress1, reason))
+		emit(task, StatusSkipped, float64(task.Course.Progress), reason, nil)
 		return
 	}
 	instance.Output(fmt.Sprintf("当前课程[%s][%d] 进度: %s", task.Course.Name, task.Course.ID, task.Course.Progress1))
+	emit(task, StatusRunning, float64(task.Course.Progress), "", nil)
+
+	// 学习时段结束时暂停, 重新入队等待下一个时段
+	schedule := config.EffectiveSettings(task.User).Schedule()
+	if end, ok := schedule.End(time.Now()); ok {
+		ctx, cancel := context.WithDeadline(context.Background(), end)
+		defer cancel()
+		instance.Context = ctx
+	}
 	err = instance.StudyCourse(task.Course)
+	if errors.Is(err, context.DeadlineExceeded) {
+		next := schedule.Next(time.Now())
+		message := fmt.Sprintf("学习时段结束, %s 继续", next.Format("01-02 15:04"))
+		instance.Output(fmt.Sprintf("课程[%s][%d]: %s", task.Course.Name, task.Course.ID, message))
+		emit(task, StatusPaused, currentProgress(task), message, nil)
+		queue.push(task)
+		return
+	}
+	// 还有没到解锁时间的节点, 解锁后重新入队
+	var locked *yinghua.LockedError
+	if errors.As(err, &locked) {
+		instance.Output(fmt.Sprintf("课程[%s][%d]: %s, 到时继续", task.Course.Name, task.Course.ID, locked.Error()))
+		emit(task, StatusWaiting, currentProgress(task), locked.Error(), nil)
+		queue.pushAt(task, locked.Until)
+		return
+	}
 	if err != nil {
 		instance.OutputWith(fmt.Sprintf("课程[%s][%d]: %s", task.Course.Name, task.Course.ID, err.Error()), logrus.Errorf)
+		emit(task, StatusFailed, float64(task.Course.Progress), "", err)
+		return
+	}
+
+	// 与平台核对学习结果, 未完成的节点重新学习
+	name := fmt.Sprintf("课程[%s][%d]", task.Course.Name, task.Course.ID)
+	verification, err := verify(instance, task)
+	if err != nil {
+		verification.Message = "核对失败: " + err.Error()
+		instance.OutputWith(fmt.Sprintf("%s 学习结束, %s", name, verification.Message), logrus.Warnf)
+	} else {
+		instance.Output(fmt.Sprintf("%s 学习结束, 第 %d 次核对: %s", name, verification.Round, verification.Message))
+	}
+	recordVerification(task, verification)
+	if err == nil && !verification.Verified {
+		if verification.Round > maxVerifyRounds {
+			message := "核对未通过: " + verification.Message
+			instance.OutputWith(fmt.Sprintf("%s %s, 已重新学习 %d 次, 放弃", name, message, maxVerifyRounds), logrus.Errorf)
+			emit(task, StatusFailed, currentProgress(task), message, nil)
+			return
+		}
+		if err := requeueNodes(task, verification.Unfinished); err != nil {
+			instance.OutputWith(fmt.Sprintf("保存断点失败: %s", err.Error()), logrus.Warnf)
+		}
+		message := fmt.Sprintf("核对未通过, 重新学习 %d 个节点", len(verification.Unfinished))
+		instance.OutputWith(fmt.Sprintf("%s %s: %v", name, message, verification.Unfinished), logrus.Warnf)
+		emit(task, StatusPending, currentProgress(task), message, nil)
+		queue.push(task)
+		return
+	}
+
+	message := verification.Message
+	if verification.Verified {
+		message = "已核对, " + message
+	}
+	if count := skipCount(task); count > 0 {
+		message += fmt.Sprintf(", 跳过 %d 个节点", count)
+	}
+	emit(task, StatusDone, 1, message, nil)
+	err = updateCheckpoint(task, func(checkpoint *Checkpoint) {
+		checkpoint.Done = true
+	})
+	if err != nil {
+		instance.OutputWith(fmt.Sprintf("保存断点失败: %s", err.Error()), logrus.Warnf)
 	}
 
 }
@@ -0,0 +1,49 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestStartRunResetsState(t *testing.T) {
+	user := config.User{Username: "frank", BaseURL: "https://reset.example.com"}
+	previous := Task{User: user, Course: types.CoursesList{ID: 21, Name: "上一次的课程"}}
+	emit(previous, StatusRunning, 0.3, "", nil)
+	if !SetPriority(previous.Key(), 5) {
+		t.Fatal("设置优先级失败")
+	}
+	verifyRounds.Lock()
+	verifyRounds.data[previous.Key()] = 2
+	verifyRounds.Unlock()
+	queue.push(previous)
+
+	next := Task{User: user, Course: types.CoursesList{ID: 22, Name: "本次的课程"}}
+	AddTask(next)
+	tasks := startRun()
+	if len(tasks) != 1 || tasks[0].Key() != next.Key() {
+		t.Fatalf("应取出本次运行的任务, 得到 %+v", tasks)
+	}
+	if len(Tasks) != 0 {
+		t.Fatal("取出后应清空 Tasks")
+	}
+	if states := States(); len(states) != 0 {
+		t.Fatalf("上一次运行的状态应被清空, 得到 %+v", states)
+	}
+	if Priority(previous) != 0 {
+		t.Fatal("上一次运行设置的优先级应被清空")
+	}
+	estimates.Lock()
+	left := len(estimates.data)
+	estimates.Unlock()
+	verifyRounds.Lock()
+	rounds := len(verifyRounds.data)
+	verifyRounds.Unlock()
+	if left != 0 || rounds != 0 {
+		t.Fatalf("估算和核对次数应被清空, 剩余 %d, %d", left, rounds)
+	}
+	if order := queue.order(); len(order) != 0 {
+		t.Fatalf("队列应被清空, 得到 %v", order)
+	}
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/sirupsen/logrus"
+)
+
+// Todos 全部任务的待办事项, 按账号和课程排序
+func Todos() []todo.Item {
+	var items []todo.Item
+	for _, state := range States() {
+		items = append(items, state.Todos...)
+	}
+	return items
+}
+
+// UserTodos 运行中的任务已经获取的待办事项, 按账号分组, 不会重新登录
+// user 为账号标识或用户名, 为空时包括全部账号, 没有对应的任务时返回 false
+func UserTodos(user string) ([]todo.User, bool) {
+	users := []todo.User{}
+	for _, state := range States() {
+		if !state.belongsTo(user) {
+			continue
+		}
+		if len(users) == 0 || users[len(users)-1].User != state.User {
+			users = append(users, todo.User{User: state.User})
+		}
+		last := &users[len(users)-1]
+		last.Items = append(last.Items, state.Todos...)
+	}
+	return users, len(users) > 0
+}
+
+// logTodos 全部任务结束后按账号输出需要手动完成的节点
+func logTodos() {
+	users := todo.Group(Todos())
+	if len(users) > 0 {
+		logrus.Warnf("注意: %s", todo.Note)
+	}
+	for _, user := range users {
+		logrus.Warnf("[%s] 还有 %d 个节点需要手动完成:", user.User, len(user.Items))
+		for _, item := range user.Items {
+			logrus.Warnf("[%s] 课程[%s] %s [%s](%s), 解锁时间: %s, 课程结束: %s", user.User, item.Course, item.Chapter,
+				item.Node, item.Kind, todo.Unlock(item), item.EndDate)
+		}
+	}
+}
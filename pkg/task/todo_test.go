@@ -0,0 +1,31 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/todo"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestUserTodosUsesEngineData(t *testing.T) {
+	carol := config.User{Username: "carol", BaseURL: "https://todo.example.com"}
+	dave := config.User{Username: "dave", BaseURL: "https://todo.example.com"}
+	first := Task{User: carol, Course: types.CoursesList{ID: 11, Name: "高数"}}
+	second := Task{User: dave, Course: types.CoursesList{ID: 12, Name: "英语"}}
+	emit(first, StatusRunning, 0, "", nil)
+	emit(second, StatusSkipped, 1, "课程已完成", nil)
+	recordTodos(first, []todo.Item{{User: carol.Key(), CourseID: 11, Course: "高数", Node: "期末考试", Kind: todo.KindExam}})
+
+	users, ok := UserTodos("carol")
+	if !ok || len(users) != 1 || len(users[0].Items) != 1 || users[0].Items[0].Node != "期末考试" {
+		t.Fatalf("应返回 carol 的待办事项, 得到 %+v", users)
+	}
+	users, ok = UserTodos(dave.Key())
+	if !ok || len(users) != 1 || len(users[0].Items) != 0 {
+		t.Fatalf("没有待办事项的账号也应返回, 得到 %+v", users)
+	}
+	if users, ok := UserTodos("erin"); ok || users == nil {
+		t.Fatalf("没有任务的账号应返回 false 和空列表, 得到 %+v, %v", users, ok)
+	}
+}
@@ -0,0 +1,125 @@
+package task
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/yinghua"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// maxVerifyRounds 核对未通过时最多重新学习的次数
+const maxVerifyRounds = 2
+
+// Verification 课程学习结束后与平台核对的结果
+type Verification struct {
+	Verified     bool    `json:"verified"`
+	Progress     float32 `json:"progress"`
+	VideoLearned int     `json:"video_learned"`
+	VideoCount   int     `json:"video_count"`
+	// Unfinished 平台显示未完成且已经解锁的视频节点
+	Unfinished []int `json:"unfinished,omitempty"`
+	// Round 第几次核对
+	Round   int       `json:"round"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// 每个任务已经核对的次数, key为任务标识
+var verifyRounds = struct {
+	sync.Mutex
+	data map[string]int
+}{data: map[string]int{}}
+
+// verify 重新获取课程列表和章节, 核对平台记录的进度和每个视频节点的状态
+func verify(instance *yinghua.YingHua, task Task) (Verification, error) {
+	verifyRounds.Lock()
+	verifyRounds.data[task.Key()]++
+	result := Verification{Round: verifyRounds.data[task.Key()], Time: time.Now()}
+	verifyRounds.Unlock()
+
+	err := instance.GetCourses()
+	if err != nil {
+		return result, fmt.Errorf("获取课程失败: %w", err)
+	}
+	var course *types.CoursesList
+	for index := range instance.Courses {
+		if instance.Courses[index].ID == task.Course.ID {
+			course = &instance.Courses[index]
+			break
+		}
+	}
+	if course == nil {
+		return result, fmt.Errorf("课程列表中已经没有该课程")
+	}
+	result.Progress = course.Progress
+	result.VideoLearned = course.VideoLearned
+	result.VideoCount = course.VideoCount
+
+	chapters, err := instance.GetChapters(*course)
+	if err != nil {
+		return result, fmt.Errorf("获取章节失败: %w", err)
+	}
+	recordHistory(task, *course, chapters)
+	updateCourse(task, *course)
+	now := time.Now()
+	locked := 0
+	for _, chapter := range chapters {
+		for _, node := range chapter.NodeList {
+			if !node.TabVideo || node.VideoState == 2 {
+				continue
+			}
+			// 未解锁的节点已经记录在跳过的节点中
+			if node.NodeLock != 0 || !yinghua.UnlockAt(node, now).IsZero() {
+				locked++
+				continue
+			}
+			result.Unfinished = append(result.Unfinished, node.ID)
+		}
+	}
+	result.Verified = len(result.Unfinished) == 0
+	result.Message = fmt.Sprintf("平台进度 %.f%%, 视频 %d/%d", result.Progress*100, result.VideoLearned, result.VideoCount)
+	if locked > 0 {
+		result.Message += fmt.Sprintf(", %d 个未解锁", locked)
+	}
+	if !result.Verified {
+		result.Message += fmt.Sprintf(", %d 个未完成", len(result.Unfinished))
+	} else if result.VideoLearned+locked < result.VideoCount {
+		// 节点都已完成, 课程列表中的统计通常会延迟更新
+		result.Message += ", 课程统计尚未更新"
+	}
+	return result, nil
+}
+
+// requeueNodes 从断点中删除未完成的节点, 重新学习时不再跳过
+func requeueNodes(task Task, nodes []int) error {
+	unfinished := map[int]bool{}
+	for _, id := range nodes {
+		unfinished[id] = true
+	}
+	return updateCheckpoint(task, func(checkpoint *Checkpoint) {
+		kept := checkpoint.Nodes[:0]
+		for _, id := range checkpoint.Nodes {
+			if !unfinished[id] {
+				kept = append(kept, id)
+			}
+		}
+		checkpoint.Nodes = kept
+		checkpoint.Done = false
+	})
+}
+
+// logVerifications 全部任务结束后输出每门课程的核对结果
+func logVerifications() {
+	for _, state := range States() {
+		if state.Verification == nil {
+			continue
+		}
+		result := "未通过"
+		if state.Verification.Verified {
+			result = "通过"
+		}
+		logrus.Infof("[%s] 课程[%s][%d] 核对%s: %s", state.User, state.Course, state.CourseID, result, state.Verification.Message)
+	}
+}
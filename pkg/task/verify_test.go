@@ -0,0 +1,61 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestVerifyRequeuesUnfinishedNodes(t *testing.T) {
+	// 节点12第一次提交时平台返回已完成, 但章节中的状态仍是未完成
+	platform := &fakePlatform{courses: []int{1}, finished: map[int]bool{}, lost: map[int]int{12: 1}, videos: map[int]int{}}
+	user := useFakePlatform(t, platform)
+	task := Task{User: user, Course: types.CoursesList{ID: 1, Name: "课程1", State: 1, EndDate: "2099-01-01"}}
+	AddTask(task)
+	Start()
+
+	var state *State
+	for _, item := range States() {
+		if item.Key == task.Key() {
+			item := item
+			state = &item
+		}
+	}
+	if state == nil || state.Status != StatusDone {
+		t.Fatalf("重新学习后课程应完成, 得到 %+v", state)
+	}
+	if state.Verification == nil || !state.Verification.Verified || state.Verification.Round != 2 {
+		t.Fatalf("第2次核对应通过, 得到 %+v", state.Verification)
+	}
+	requeued := false
+	for _, transition := range state.History {
+		if transition.To == StatusPending && transition.Reason == "核对未通过, 重新学习 1 个节点" {
+			requeued = true
+		}
+	}
+	if !requeued {
+		t.Fatalf("核对未通过时应重新入队, 状态记录为 %+v", state.History)
+	}
+
+	platform.Lock()
+	videos := platform.videos
+	platform.Unlock()
+	if videos[11] != 1 || videos[12] != 2 {
+		t.Fatalf("只应重新学习未完成的节点12, 提交次数为 %v", videos)
+	}
+	checkpoint, ok := loadedCheckpoint(t, task)
+	if !ok || !checkpoint.Done || len(checkpoint.Nodes) != 2 {
+		t.Fatalf("断点应记录2个节点并标记完成, 得到 %+v", checkpoint)
+	}
+}
+
+// loadedCheckpoint 读取任务的断点
+func loadedCheckpoint(t *testing.T, task Task) (Checkpoint, bool) {
+	t.Helper()
+	data, err := LoadCheckpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, ok := data[task.Key()]
+	return checkpoint, ok
+}
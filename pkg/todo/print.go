@@ -0,0 +1,63 @@
+package todo
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Print 以表格形式按账号输出待办事项
+func Print(writer io.Writer, users []User) error {
+	fmt.Fprintf(writer, "注意: %s\n\n", Note)
+	for _, user := range users {
+		if user.Error != "" {
+			fmt.Fprintf(writer, "账号 %s: %s\n", user.User, user.Error)
+			if len(user.Items) == 0 {
+				fmt.Fprintln(writer)
+				continue
+			}
+		}
+		if len(user.Items) == 0 {
+			fmt.Fprintf(writer, "账号 %s 没有需要手动完成的节点\n\n", user.User)
+			continue
+		}
+		fmt.Fprintf(writer, "账号 %s, 需要手动完成 %d 个节点\n", user.User, len(user.Items))
+		table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "  课程\t结束时间\t章节\t节点\t类型\t解锁时间")
+		for _, item := range user.Items {
+			fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\t%s\n", item.Course, item.EndDate, item.Chapter, item.Node, item.Kind, Unlock(item))
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(writer)
+	}
+	return nil
+}
+
+// Unlock 解锁时间的说明
+func Unlock(item Item) string {
+	switch {
+	case item.UnlockTime != "":
+		return item.UnlockTime
+	case item.Locked:
+		return "需要先完成前面的节点"
+	}
+	return "已解锁"
+}
+
+// Group 按账号分组, 保持账号第一次出现的顺序
+func Group(items []Item) []User {
+	var users []User
+	index := map[string]int{}
+	for _, item := range items {
+		i, ok := index[item.User]
+		if !ok {
+			i = len(users)
+			index[item.User] = i
+			users = append(users, User{User: item.User})
+		}
+		users[i].Items = append(users[i].Items, item)
+	}
+	return users
+}
@@ -0,0 +1,141 @@
+package todo
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"strings"
+	"time"
+)
+
+// 节点类型
+const (
+	KindVideo = "视频"
+	KindWork  = "作业"
+	KindExam  = "考试"
+	KindVote  = "投票"
+	KindFile  = "资料"
+	KindOther = "其他"
+)
+
+// Item 需要手动完成的非视频节点
+type Item struct {
+	User     string `json:"user"`
+	CourseID int    `json:"course_id"`
+	Course   string `json:"course"`
+	// EndDate 课程结束时间, 需要在这之前完成
+	EndDate string `json:"end_date"`
+	Chapter string `json:"chapter"`
+	NodeID  int    `json:"node_id"`
+	Node    string `json:"node"`
+	Kind    string `json:"kind"`
+	// UnlockTime 平台给出的解锁时间, 为空表示没有限制
+	UnlockTime string `json:"unlock_time,omitempty"`
+	Locked     bool   `json:"locked"`
+}
+
+// Note 待办事项的说明, 平台只返回视频节点的完成状态
+const Note = "平台不返回作业、考试等节点的完成状态, 已经完成的也会列出, 请以平台为准"
+
+// User 账号的待办事项
+type User struct {
+	User string `json:"user"`
+	// Error 登录、获取课程失败, 或部分课程获取章节失败的原因, 多个原因以分号分隔
+	Error string `json:"error,omitempty"`
+	Items []Item `json:"items"`
+}
+
+// Kind 节点类型
+func Kind(node types.ChaptersNodeList) string {
+	switch {
+	case node.TabVideo:
+		return KindVideo
+	case node.TabWork:
+		return KindWork
+	case node.TabExam:
+		return KindExam
+	case node.TabVote:
+		return KindVote
+	case node.TabFile:
+		return KindFile
+	}
+	return KindOther
+}
+
+// Collect 列出课程中需要手动完成的非视频节点
+// 平台不返回非视频节点的完成状态, 已经完成的作业、考试也会列出, 见 Note
+func Collect(user string, course types.CoursesList, chapters []types.ChaptersList, now time.Time) []Item {
+	var items []Item
+	for _, chapter := range chapters {
+		for _, node := range chapter.NodeList {
+			if node.TabVideo {
+				continue
+			}
+			item := Item{
+				User:       user,
+				CourseID:   course.ID,
+				Course:     course.Name,
+				EndDate:    course.EndDate,
+				Chapter:    chapter.Name,
+				NodeID:     node.ID,
+				Node:       node.Name,
+				Kind:       Kind(node),
+				UnlockTime: node.UnlockTime,
+				Locked:     node.NodeLock != 0,
+			}
+			if until := yinghua.UnlockAt(node, now); !until.IsZero() {
+				item.UnlockTime = until.Format("2006-01-02 15:04")
+				item.Locked = true
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Ended 课程是否已经结束, 结束的课程没有待办事项
+func Ended(course types.CoursesList) bool {
+	return course.State == 2
+}
+
+// Build 登录全部账号, 列出每个账号未结束课程中的非视频节点, 不会提交任何学习记录
+func Build(users []config.User) []User {
+	var result []User
+	for _, user := range users {
+		result = append(result, BuildUser(user))
+	}
+	return result
+}
+
+// BuildUser 列出单个账号的待办事项
+func BuildUser(user config.User) User {
+	result := User{User: user.Key()}
+	instance := yinghua.New(user)
+	err := instance.Login()
+	if err != nil {
+		result.Error = "登录失败: " + err.Error()
+		return result
+	}
+	err = instance.GetCourses()
+	if err != nil {
+		result.Error = "获取课程失败: " + err.Error()
+		return result
+	}
+	now := time.Now()
+	var errs []string
+	for _, course := range instance.Courses {
+		if Ended(course) {
+			continue
+		}
+		// 单门课程获取章节失败时记录原因, 继续列出其他课程
+		chapters, err := instance.GetChapters(course)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("课程[%s] 获取章节失败: %s", course.Name, err.Error()))
+			continue
+		}
+		result.Items = append(result.Items, Collect(user.Key(), course, chapters, now)...)
+	}
+	result.Error = strings.Join(errs, "; ")
+	return result
+}
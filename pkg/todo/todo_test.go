@@ -0,0 +1,77 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/config"
+)
+
+// fakePlatform 模拟学习平台, 课程1获取章节失败, 课程2有一个作业节点
+func fakePlatform(writer http.ResponseWriter, request *http.Request) {
+	_ = request.ParseForm()
+	body := map[string]interface{}{"_code": 0, "msg": "ok"}
+	switch request.URL.Path {
+	case "/api/login.json":
+		body["result"] = map[string]interface{}{"data": map[string]interface{}{"token": "token"}}
+	case "/api/course.json":
+		body["result"] = map[string]interface{}{"list": []map[string]interface{}{
+			{"id": 1, "name": "课程1", "state": 1}, {"id": 2, "name": "课程2", "state": 1}}}
+	case "/api/course/chapter.json":
+		courseID, _ := strconv.Atoi(request.FormValue("courseId"))
+		if courseID == 1 {
+			body = map[string]interface{}{"_code": 7, "msg": "课程不存在"}
+			break
+		}
+		body["result"] = map[string]interface{}{"list": []map[string]interface{}{{"id": 20, "name": "第一章", "nodeList": []map[string]interface{}{
+			{"id": 21, "name": "视频", "tabVideo": true},
+			{"id": 22, "name": "作业", "tabWork": true},
+		}}}}
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(body)
+}
+
+func TestBuildUserContinuesAfterChapterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(fakePlatform))
+	defer server.Close()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Conf
+	defer func() {
+		config.Conf = old
+		_ = os.Chdir(wd)
+	}()
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{BreakerThreshold: -1, RetryCount: -1}}}
+
+	result := BuildUser(config.User{Username: "tester", Password: "pw", BaseURL: server.URL})
+	if !strings.Contains(result.Error, "课程1") || !strings.Contains(result.Error, "课程不存在") {
+		t.Fatalf("应记录课程1获取章节失败的原因, 得到 %q", result.Error)
+	}
+	if len(result.Items) != 1 || result.Items[0].CourseID != 2 || result.Items[0].Kind != KindWork {
+		t.Fatalf("应继续列出课程2的作业, 得到 %+v", result.Items)
+	}
+
+	var buffer strings.Builder
+	if err := Print(&buffer, []User{result}); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{Note, "课程不存在", "作业"} {
+		if !strings.Contains(buffer.String(), text) {
+			t.Fatalf("输出中应包括 %q:\n%s", text, buffer.String())
+		}
+	}
+}
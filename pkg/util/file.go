@@ -0,0 +1,203 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// DefaultBackups 默认保留的备份份数
+const DefaultBackups = 3
+
+// 同一文件的写入串行化, 避免并发写入时备份轮转互相覆盖
+var fileLocks sync.Map
+
+func lockFile(filename string) func() {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		abs = filename
+	}
+	value, _ := fileLocks.LoadOrStore(abs, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// BackupName 返回第n份备份的文件名, n从1开始, 1为最近一份
+func BackupName(filename string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", filename, n)
+}
+
+// WriteFileAtomic 原子写入文件
+// 先写入同目录下的临时文件并fsync, 再重命名覆盖目标文件, 中途崩溃不会留下写了一半的文件
+// backups > 0 时, 覆盖前保留最近 backups 份旧文件
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode, backups int) (err error) {
+	unlock := lockFile(filename)
+	defer unlock()
+
+	dir := filepath.Dir(filename)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	if backups > 0 {
+		if err = rotateBackups(filename, backups, perm); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// rotateBackups 轮转备份: .bak.1 -> .bak.2 ..., 当前文件复制为 .bak.1
+// 备份和新文件使用相同的权限, 以前写入的权限更宽的备份也会改为该权限
+func rotateBackups(filename string, backups int, perm os.FileMode) error {
+	if _, err := os.Stat(filename); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	_ = os.Remove(BackupName(filename, backups))
+	for n := backups - 1; n >= 1; n-- {
+		err := os.Rename(BackupName(filename, n), BackupName(filename, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			if err = os.Chmod(BackupName(filename, n+1), perm); err != nil {
+				return err
+			}
+		}
+	}
+	// 复制而不是重命名, 保证任意时刻目标文件都存在
+	return copyFile(filename, BackupName(filename, 1), perm)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// 已经存在的文件不会按 OpenFile 的权限修改
+	if err = out.Chmod(perm); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir 刷新目录项, 保证重命名落盘, windows不支持对目录fsync
+func syncDir(dir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// ReadFileWithBackup 读取文件, 文件不存在或 valid 校验失败时依次尝试备份
+// 返回实际读取的文件名
+func ReadFileWithBackup(filename string, backups int, valid func([]byte) error) ([]byte, string, error) {
+	candidates := []string{filename}
+	for n := 1; n <= backups; n++ {
+		candidates = append(candidates, BackupName(filename, n))
+	}
+	var firstErr error
+	for _, name := range candidates {
+		data, err := os.ReadFile(name)
+		if err == nil && valid != nil {
+			err = valid(data)
+		}
+		if err == nil {
+			return data, name, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, "", firstErr
+}
+
+// SaveJson 以JSON格式原子写入文件, 并保留备份
+func SaveJson(filename string, v interface{}) error {
+	return SaveJsonWith(filename, v, 0644, DefaultBackups)
+}
+
+// SaveJsonWith 以JSON格式原子写入文件, 指定权限和备份份数
+func SaveJsonWith(filename string, v interface{}, perm os.FileMode, backups int) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, data, perm, backups)
+}
+
+// LoadJson 读取JSON文件, 主文件损坏时自动回退到最近的可用备份
+func LoadJson(filename string, v interface{}) error {
+	data, name, err := ReadFileWithBackup(filename, DefaultBackups, func(data []byte) error {
+		if !json.Valid(data) {
+			return errors.New("JSON格式错误")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if name != filename {
+		logrus.Warnf("%s 已损坏, 使用备份 %s", filename, name)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,174 @@
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readString(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("读取 %s 失败: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestWriteFileAtomicLeftoverTemp(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.json")
+	if err := WriteFileAtomic(filename, []byte(`{"v":1}`), 0644, DefaultBackups); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写入临时文件后进程崩溃, 临时文件留在目录中
+	leftover := filepath.Join(dir, ".data.json.tmp-crashed")
+	if err := os.WriteFile(leftover, []byte(`{"v":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var value map[string]int
+	if err := LoadJson(filename, &value); err != nil || value["v"] != 1 {
+		t.Fatalf("崩溃后应读取到完整的旧文件, 得到 %v, %v", value, err)
+	}
+	if err := WriteFileAtomic(filename, []byte(`{"v":2}`), 0644, DefaultBackups); err != nil {
+		t.Fatalf("留有临时文件时写入失败: %v", err)
+	}
+	if got := readString(t, filename); got != `{"v":2}` {
+		t.Fatalf("写入后内容为 %q", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, ".tmp-") && name != filepath.Base(leftover) {
+			t.Fatalf("写入成功后不应留下临时文件 %s", name)
+		}
+	}
+}
+
+func TestLoadJsonFallsBackToNewestValidBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		main    string
+		backups []string
+		want    int
+	}{
+		{name: "主文件被截断", main: `{"v":`, backups: []string{`{"v":3}`, `{"v":2}`}, want: 3},
+		{name: "主文件为空", main: ``, backups: []string{`{"v":3}`}, want: 3},
+		{name: "最近的备份也损坏", main: `garbage`, backups: []string{`{"v"`, `{"v":2}`, `{"v":1}`}, want: 2},
+		{name: "主文件正常", main: `{"v":4}`, backups: []string{`{"v":3}`}, want: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "data.json")
+			if err := os.WriteFile(filename, []byte(test.main), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for i, backup := range test.backups {
+				if err := os.WriteFile(BackupName(filename, i+1), []byte(backup), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var value map[string]int
+			if err := LoadJson(filename, &value); err != nil {
+				t.Fatal(err)
+			}
+			if value["v"] != test.want {
+				t.Fatalf("读取到 %d, 应为 %d", value["v"], test.want)
+			}
+		})
+	}
+}
+
+func TestLoadJsonAllCorrupted(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(filename, []byte(`{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(BackupName(filename, 1), []byte(`}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var value map[string]int
+	if err := LoadJson(filename, &value); err == nil {
+		t.Fatal("全部损坏时应返回错误")
+	}
+}
+
+func TestBackupRotationCapped(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.json")
+	for v := 1; v <= DefaultBackups+3; v++ {
+		data, _ := json.Marshal(map[string]int{"v": v})
+		if err := WriteFileAtomic(filename, data, 0644, DefaultBackups); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := DefaultBackups + 3
+	for n := 1; n <= DefaultBackups; n++ {
+		var value map[string]int
+		if err := json.Unmarshal([]byte(readString(t, BackupName(filename, n))), &value); err != nil {
+			t.Fatal(err)
+		}
+		if value["v"] != last-n {
+			t.Fatalf("第 %d 份备份为 %d, 应为 %d", n, value["v"], last-n)
+		}
+	}
+	if _, err := os.Stat(BackupName(filename, DefaultBackups+1)); !os.IsNotExist(err) {
+		t.Fatalf("备份不应超过 %d 份", DefaultBackups)
+	}
+}
+
+func TestSaveJsonWithoutBackups(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "session.json")
+	for i := 0; i < 3; i++ {
+		if err := SaveJsonWith(filename, map[string]int{"v": i}, 0600, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(BackupName(filename, 1)); !os.IsNotExist(err) {
+		t.Fatal("backups 为0时不应生成备份")
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 && os.PathSeparator == '/' {
+		t.Fatalf("文件权限为 %o, 应为 600", perm)
+	}
+}
+
+func TestBackupsUseFilePerm(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("只检查类Unix系统的文件权限")
+	}
+	filename := filepath.Join(t.TempDir(), "config.json")
+	// 以前写入的权限更宽的文件和备份
+	for v := 1; v <= 2; v++ {
+		if err := WriteFileAtomic(filename, []byte(`{"v":1}`), 0644, DefaultBackups); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for v := 1; v <= 2; v++ {
+		if err := WriteFileAtomic(filename, []byte(`{"v":2}`), 0600, DefaultBackups); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := []string{filename}
+	for n := 1; n <= DefaultBackups; n++ {
+		names = append(names, BackupName(filename, n))
+	}
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Fatalf("%s 的权限为 %o, 应为 600", filepath.Base(name), perm)
+		}
+	}
+}
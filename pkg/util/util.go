@@ -3,7 +3,7 @@ package util
 import (
 	"bufio"
 	"bytes"
-	"github.com/aoaostar/mooc/pkg/config"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"os"
 	"runtime"
@@ -11,13 +11,6 @@ import (
 	"strings"
 )
 
-func SaveJson(filename, data string) {
-
-	file, _ := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0744)
-
-	_, _ = file.WriteString(data)
-}
-
 func GetGid() (gid uint64) {
 	b := make([]byte, 64)
 	b = b[:runtime.Stack(b, false)]
@@ -71,7 +64,8 @@ func ReadText(filename string, line int, limit int) ([]string, error) {
 	return data, nil
 }
 
-func Copyright() {
+// Copyright 输出版本信息
+func Copyright(version string) {
 	logrus.Infof(`
 +---------------------------------------------------------------------------------------+
     ___       ___       ___       ___       ___       ___       ___       ___   
@@ -85,5 +79,17 @@ func Copyright() {
                    Github: https://github.com/aoaostar/mooc
             Version: %s Runtime: %s/%s Go Version: %s Author: Pluto
 +---------------------------------------------------------------------------------------+
-`, config.VERSION, runtime.GOOS, runtime.GOARCH, runtime.Version())
+`, version, runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
+
+// FormatSeconds 格式化时长
+func FormatSeconds(seconds int) string {
+	if seconds <= 0 {
+		return "0分钟"
+	}
+	minutes := (seconds + 59) / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%d分钟", minutes)
+	}
+	return fmt.Sprintf("%d小时%d分钟", minutes/60, minutes%60)
 }
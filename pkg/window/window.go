@@ -0,0 +1,238 @@
+package window
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window 每周重复的学习时段, 如 "mon-fri 08:00-23:00"
+// 星期可以省略, 表示每天; 结束时间不大于开始时间时表示跨过零点
+type Window struct {
+	Text  string
+	Days  [7]bool
+	Start int
+	End   int
+}
+
+// Windows 多个学习时段, 为空表示任何时间都可以学习
+type Windows []Window
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse 解析学习时段
+// 格式: [星期] 开始-结束, 星期支持 *、mon、mon-fri、weekdays、weekends, 时间为 HH:MM, 结束时间可以是 24:00
+func Parse(text string) (Window, error) {
+	w := Window{Text: strings.TrimSpace(text)}
+	fields := strings.Fields(strings.ToLower(w.Text))
+	var days, clock string
+	switch len(fields) {
+	case 1:
+		days, clock = "*", fields[0]
+	case 2:
+		days, clock = fields[0], fields[1]
+	default:
+		return w, fmt.Errorf("学习时段 %q 格式错误, 应为 \"mon-fri 08:00-23:00\"", text)
+	}
+
+	err := w.parseDays(days)
+	if err != nil {
+		return w, fmt.Errorf("学习时段 %q: %s", text, err.Error())
+	}
+	parts := strings.Split(clock, "-")
+	if len(parts) != 2 {
+		return w, fmt.Errorf("学习时段 %q 时间格式错误, 应为 08:00-23:00", text)
+	}
+	if w.Start, err = parseClock(parts[0]); err != nil {
+		return w, fmt.Errorf("学习时段 %q: %s", text, err.Error())
+	}
+	if w.End, err = parseClock(parts[1]); err != nil {
+		return w, fmt.Errorf("学习时段 %q: %s", text, err.Error())
+	}
+	if w.Start == 24*60 {
+		return w, fmt.Errorf("学习时段 %q 开始时间不能是 24:00", text)
+	}
+	return w, nil
+}
+
+// ParseAll 解析全部学习时段
+func ParseAll(texts []string) (Windows, error) {
+	var windows Windows
+	for _, text := range texts {
+		w, err := Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func (w *Window) parseDays(text string) error {
+	switch text {
+	case "*":
+		text = "sun-sat"
+	case "weekdays":
+		text = "mon-fri"
+	case "weekends":
+		text = "sat-sun"
+	}
+	parts := strings.Split(text, "-")
+	if len(parts) > 2 {
+		return fmt.Errorf("星期 %q 格式错误", text)
+	}
+	from, ok := dayNames[parts[0]]
+	if !ok {
+		return fmt.Errorf("无法识别的星期 %q, 应为 mon、tue、wed、thu、fri、sat、sun", parts[0])
+	}
+	to := from
+	if len(parts) == 2 {
+		if to, ok = dayNames[parts[1]]; !ok {
+			return fmt.Errorf("无法识别的星期 %q, 应为 mon、tue、wed、thu、fri、sat、sun", parts[1])
+		}
+	}
+	// 支持 fri-mon 这样跨周的范围
+	for day := from; ; day = (day + 1) % 7 {
+		w.Days[day] = true
+		if day == to {
+			break
+		}
+	}
+	return nil
+}
+
+func parseClock(text string) (int, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("时间 %q 格式错误, 应为 HH:MM", text)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("时间 %q 小时错误", text)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || hour == 24 && minute != 0 {
+		return 0, fmt.Errorf("时间 %q 分钟错误", text)
+	}
+	return hour*60 + minute, nil
+}
+
+// occurrence 从 day 当天开始的一次时段
+func (w Window) occurrence(day time.Time) (time.Time, time.Time) {
+	start := day.Add(time.Duration(w.Start) * time.Minute)
+	end := day.Add(time.Duration(w.End) * time.Minute)
+	if w.End <= w.Start {
+		end = end.Add(24 * time.Hour)
+	}
+	return start, end
+}
+
+// contains now 是否在时段内, 返回这次时段的结束时间
+func (w Window) contains(now time.Time) (time.Time, bool) {
+	today := midnight(now)
+	// 前一天开始的跨零点时段也要检查
+	for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+		start, end := w.occurrence(day)
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Always 是否任何时间都可以学习
+func (ws Windows) Always() bool {
+	return len(ws) == 0
+}
+
+// Open now 是否在任意一个时段内
+func (ws Windows) Open(now time.Time) bool {
+	if ws.Always() {
+		return true
+	}
+	for _, w := range ws {
+		if _, ok := w.contains(now); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// End 当前连续可学习时间的结束时间, 不在时段内或任何时间都可以学习时返回 false
+// 相互衔接的时段视为连续, 如 "* 08:00-24:00" 和 "* 00:00-02:00"
+func (ws Windows) End(now time.Time) (time.Time, bool) {
+	if ws.Always() {
+		return time.Time{}, false
+	}
+	var end time.Time
+	open := false
+	limit := now.AddDate(0, 0, 8)
+	for at := now; at.Before(limit); {
+		extended := false
+		for _, w := range ws {
+			if e, ok := w.contains(at); ok && e.After(end) {
+				end = e
+				extended = true
+			}
+		}
+		if !extended {
+			break
+		}
+		open = true
+		at = end
+	}
+	if !open || !end.Before(limit) {
+		// 每天24小时都在时段内
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// Next 下一次可以学习的时间, 当前在时段内时返回 now, 没有任何可用时段时返回零值
+func (ws Windows) Next(now time.Time) time.Time {
+	if ws.Open(now) {
+		return now
+	}
+	var next time.Time
+	today := midnight(now)
+	for offset := 0; offset <= 7; offset++ {
+		day := today.AddDate(0, 0, offset)
+		for _, w := range ws {
+			if !w.Days[day.Weekday()] {
+				continue
+			}
+			start, _ := w.occurrence(day)
+			if start.After(now) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+func (ws Windows) String() string {
+	if ws.Always() {
+		return "全天"
+	}
+	var texts []string
+	for _, w := range ws {
+		texts = append(texts, w.Text)
+	}
+	return strings.Join(texts, "; ")
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,144 @@
+package window
+
+import (
+	"testing"
+	"time"
+)
+
+// at 2024-01-01 是星期一
+func at(day int, clock string) time.Time {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(2024, 1, day, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+}
+
+func mustParse(t *testing.T, texts ...string) Windows {
+	t.Helper()
+	windows, err := ParseAll(texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return windows
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"mon fri 08:00-23:00",
+		"08:00",
+		"08:00-23:00-24:00",
+		"25:00-26:00",
+		"08:60-23:00",
+		"24:00-06:00",
+		"08:00-24:30",
+		"8-23",
+		"mon-fri-sat 08:00-23:00",
+		"xyz 08:00-23:00",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q 应解析失败", text)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		text string
+		days [7]bool
+	}{
+		{text: "08:00-23:00", days: [7]bool{true, true, true, true, true, true, true}},
+		{text: "weekdays 08:00-23:00", days: [7]bool{false, true, true, true, true, true, false}},
+		{text: "weekends 08:00-23:00", days: [7]bool{true, false, false, false, false, false, true}},
+		{text: "fri-mon 08:00-23:00", days: [7]bool{true, true, false, false, false, true, true}},
+		{text: "WED 08:00-23:00", days: [7]bool{false, false, false, true, false, false, false}},
+	}
+	for _, test := range tests {
+		w, err := Parse(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Days != test.days {
+			t.Errorf("%q 的星期为 %v, 应为 %v", test.text, w.Days, test.days)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []string
+		now     time.Time
+		open    bool
+	}{
+		{name: "跨零点时段零点前", windows: []string{"22:00-06:00"}, now: at(1, "23:30"), open: true},
+		{name: "跨零点时段零点后", windows: []string{"22:00-06:00"}, now: at(2, "05:59"), open: true},
+		{name: "跨零点时段结束", windows: []string{"22:00-06:00"}, now: at(2, "06:00"), open: false},
+		{name: "跨零点时段白天", windows: []string{"22:00-06:00"}, now: at(2, "12:00"), open: false},
+		{name: "跨零点时段开始", windows: []string{"22:00-06:00"}, now: at(2, "22:00"), open: true},
+		{name: "前一天不在星期内", windows: []string{"mon 22:00-06:00"}, now: at(3, "01:00"), open: false},
+		{name: "周一开始的跨零点时段到周二", windows: []string{"mon 22:00-06:00"}, now: at(2, "01:00"), open: true},
+		{name: "全天零点", windows: []string{"00:00-24:00"}, now: at(1, "00:00"), open: true},
+		{name: "全天最后一分钟", windows: []string{"00:00-24:00"}, now: at(1, "23:59"), open: true},
+		{name: "多个时段第二个", windows: []string{"08:00-12:00", "14:00-18:00"}, now: at(1, "15:00"), open: true},
+		{name: "多个时段之间", windows: []string{"08:00-12:00", "14:00-18:00"}, now: at(1, "13:00"), open: false},
+		{name: "工作日的周末", windows: []string{"weekdays 08:00-23:00"}, now: at(6, "10:00"), open: false},
+		{name: "没有时段", now: at(6, "03:00"), open: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if open := mustParse(t, test.windows...).Open(test.now); open != test.open {
+				t.Fatalf("%v 在 %s 是否可学习为 %v, 应为 %v", test.windows, test.now, open, test.open)
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []string
+		now     time.Time
+		end     time.Time
+		ok      bool
+	}{
+		{name: "跨零点时段零点前", windows: []string{"22:00-06:00"}, now: at(1, "23:00"), end: at(2, "06:00"), ok: true},
+		{name: "跨零点时段零点后", windows: []string{"22:00-06:00"}, now: at(2, "03:00"), end: at(2, "06:00"), ok: true},
+		{name: "衔接的时段连续", windows: []string{"08:00-24:00", "00:00-02:00"}, now: at(1, "20:00"), end: at(2, "02:00"), ok: true},
+		{name: "每天全天", windows: []string{"00:00-24:00"}, now: at(1, "12:00"), ok: false},
+		{name: "不在时段内", windows: []string{"08:00-12:00"}, now: at(1, "13:00"), ok: false},
+		{name: "没有时段", now: at(1, "13:00"), ok: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			end, ok := mustParse(t, test.windows...).End(test.now)
+			if ok != test.ok || !end.Equal(test.end) {
+				t.Fatalf("结束时间为 %s(%v), 应为 %s(%v)", end, ok, test.end, test.ok)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []string
+		now     time.Time
+		next    time.Time
+	}{
+		{name: "已经在时段内", windows: []string{"08:00-12:00"}, now: at(1, "09:00"), next: at(1, "09:00")},
+		{name: "跨零点时段内", windows: []string{"22:00-06:00"}, now: at(2, "02:00"), next: at(2, "02:00")},
+		{name: "当天稍后", windows: []string{"22:00-06:00"}, now: at(2, "12:00"), next: at(2, "22:00")},
+		{name: "第二天", windows: []string{"08:00-12:00"}, now: at(1, "13:00"), next: at(2, "08:00")},
+		{name: "多个时段取最近的", windows: []string{"08:00-12:00", "14:00-18:00"}, now: at(1, "13:00"), next: at(1, "14:00")},
+		{name: "周末跳到周一", windows: []string{"weekdays 08:00-23:00"}, now: at(6, "10:00"), next: at(8, "08:00")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if next := mustParse(t, test.windows...).Next(test.now); !next.Equal(test.next) {
+				t.Fatalf("下一次可以学习的时间为 %s, 应为 %s", next, test.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package yinghua
+
+import (
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"strings"
+	"time"
+)
+
+// 节点跳过原因
+const (
+	SkipNotVideo = "非视频节点, 需要手动完成"
+	SkipLocked   = "节点未解锁"
+	SkipBlocked  = "需要先完成前面的节点"
+)
+
+// NodeSkip 节点跳过记录
+type NodeSkip struct {
+	Node   types.ChaptersNodeList
+	Reason string
+	// Until 按时间解锁的节点的解锁时间
+	Until time.Time
+}
+
+// LockedError 课程中还有没到解锁时间的节点, 需要在 Until 之后重新学习
+type LockedError struct {
+	Until time.Time
+	Nodes int
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%d 个节点未到解锁时间, %s 解锁", e.Nodes, e.Until.Format("01-02 15:04"))
+}
+
+// Sequential 课程是否需要按顺序解锁节点
+func Sequential(course types.CoursesList) bool {
+	return course.LineLock != "" && course.LineLock != "0"
+}
+
+// unlockLayouts 平台返回的 unlockTime 的格式, 按本地时间解析
+var unlockLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// UnlockAt 节点的解锁时间, 已解锁或没有解锁时间时返回零值
+// 没有 unlockTimeStamp 时按 unlockTime 计算
+func UnlockAt(node types.ChaptersNodeList, now time.Time) time.Time {
+	until := time.Unix(int64(node.UnlockTimeStamp), 0)
+	if node.UnlockTimeStamp == 0 {
+		until = parseUnlockTime(node.UnlockTime)
+	}
+	if until.After(now) {
+		return until
+	}
+	return time.Time{}
+}
+
+// parseUnlockTime 解析 unlockTime, 无法解析时返回零值
+func parseUnlockTime(text string) time.Time {
+	text = strings.TrimSpace(text)
+	for _, layout := range unlockLayouts {
+		if until, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return until
+		}
+	}
+	return time.Time{}
+}
+
+// studyPass 一轮学习的结果, 按顺序解锁的课程遇到未解锁的节点后停止
+type studyPass struct {
+	sequential bool
+	studied    int
+	// locked 被前面节点锁住的节点数, 有节点学完后重新获取章节再学一轮
+	locked int
+	// parked 没到解锁时间的节点
+	parked []NodeSkip
+	stop   bool
+	// done 之前几轮学完的节点
+	done map[int]bool
+	// reported 已经报告过的跳过, 多轮学习时不重复报告
+	reported map[int]string
+}
+
+// skip 记录并报告跳过的节点
+func (i *YingHua) skip(pass *studyPass, skip NodeSkip) {
+	if pass.reported[skip.Node.ID] == skip.Reason {
+		return
+	}
+	pass.reported[skip.Node.ID] = skip.Reason
+	i.Output(fmt.Sprintf("跳过[%s][nodeId=%d]: %s", skip.Node.Name, skip.Node.ID, skip.Reason))
+	if i.OnSkip != nil {
+		i.OnSkip(skip)
+	}
+}
+
+func newStudyPass(sequential bool) *studyPass {
+	return &studyPass{sequential: sequential, done: map[int]bool{}, reported: map[int]string{}}
+}
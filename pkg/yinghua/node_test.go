@@ -0,0 +1,52 @@
+package yinghua
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+func TestUnlockAt(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	later := time.Date(2024, 3, 2, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		node types.ChaptersNodeList
+		want time.Time
+	}{
+		{name: "时间戳未到", node: types.ChaptersNodeList{UnlockTimeStamp: int(later.Unix())}, want: later},
+		{name: "时间戳已过", node: types.ChaptersNodeList{UnlockTimeStamp: int(now.Add(-time.Hour).Unix())}},
+		{name: "只有解锁时间", node: types.ChaptersNodeList{UnlockTime: "2024-03-02 08:30:00"}, want: later},
+		{name: "解锁时间不带秒", node: types.ChaptersNodeList{UnlockTime: "2024-03-02 08:30"}, want: later},
+		{name: "解锁日期", node: types.ChaptersNodeList{UnlockTime: "2024-03-02"},
+			want: time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)},
+		{name: "解锁时间已过", node: types.ChaptersNodeList{UnlockTime: "2024-02-29 08:30:00"}},
+		{name: "优先使用时间戳", node: types.ChaptersNodeList{UnlockTimeStamp: int(now.Add(-time.Hour).Unix()), UnlockTime: "2024-03-02 08:30:00"}},
+		{name: "无法解析", node: types.ChaptersNodeList{UnlockTime: "明天"}},
+		{name: "没有解锁时间", node: types.ChaptersNodeList{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if until := UnlockAt(test.node, now); !until.Equal(test.want) {
+				t.Fatalf("解锁时间为 %s, 应为 %s", until, test.want)
+			}
+		})
+	}
+}
+
+func TestStudyCourseParksNodeWithUnlockTimeOnly(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 1
+	until := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	parked := videoNode(30)
+	parked.UnlockTime = until.Format("2006-01-02 15:04:05")
+	platform.chapters = []types.ChaptersList{{ID: 3, Name: "第三章", Idx: 1, NodeList: []types.ChaptersNodeList{parked}}}
+	instance := newTestInstance(t, platform)
+
+	err := instance.StudyCourse(types.CoursesList{ID: 3})
+	locked, ok := err.(*LockedError)
+	if !ok || !locked.Until.Equal(until) {
+		t.Fatalf("只有解锁时间的节点也应等待解锁, 得到 %v", err)
+	}
+}
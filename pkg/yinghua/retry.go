@@ -0,0 +1,186 @@
+package yinghua
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy 请求重试策略, 指数退避并加入随机抖动
+type RetryPolicy struct {
+	// MaxAttempts 最多请求次数, 包括第一次
+	MaxAttempts int
+	// BaseWait 第一次重试前的等待时间, 之后每次翻倍
+	BaseWait time.Duration
+	// MaxWait 单次等待时间上限
+	MaxWait time.Duration
+	// RetryCodes 可以重试的 _code, 其他非0的 _code 直接返回错误
+	RetryCodes map[int]bool
+	// Rand 返回 [0,1) 的随机数, 为nil时使用 math/rand
+	Rand func() float64
+}
+
+// NewRetryPolicy 按运行参数生成重试策略
+func NewRetryPolicy(settings config.Settings) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: settings.Retries() + 1,
+		BaseWait:    time.Duration(settings.RetryWait) * time.Second,
+		MaxWait:     time.Duration(settings.RetryMaxWait) * time.Second,
+		RetryCodes:  map[int]bool{},
+	}
+	for _, code := range settings.RetryCodes {
+		policy.RetryCodes[code] = true
+	}
+	return policy
+}
+
+// Backoff 第 attempt 次失败后的等待时间, attempt 从1开始
+// 等待时间在 [d/2, d) 之间随机, d = BaseWait * 2^(attempt-1), 不超过 MaxWait
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	wait := float64(p.BaseWait) * math.Pow(2, float64(attempt-1))
+	if p.MaxWait > 0 && wait > float64(p.MaxWait) {
+		wait = float64(p.MaxWait)
+	}
+	random := rand.Float64
+	if p.Rand != nil {
+		random = p.Rand
+	}
+	return time.Duration(wait/2 + random()*wait/2)
+}
+
+// Retryable 错误是否可以重试: 网络错误、HTTP 429 和 5xx、RetryCodes 中的 _code
+func (p RetryPolicy) Retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return p.RetryCodes[apiErr.Code]
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	return err != nil
+}
+
+// APIError 平台返回的 _code 不为0
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("_code=%d", e.Code)
+	}
+	return e.Msg
+}
+
+// HTTPError HTTP状态码错误
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return "HTTP " + e.Status
+}
+
+// check 检查响应, 返回网络错误、HTTP错误或平台错误
+func check(resp *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 400 {
+		return &HTTPError{StatusCode: resp.StatusCode(), Status: resp.Status()}
+	}
+	var status types.Status
+	if json.Unmarshal(resp.Body(), &status) == nil && status.Code != 0 {
+		return &APIError{Code: status.Code, Msg: status.Msg}
+	}
+	return nil
+}
+
+// unreachable 是否是服务器无法访问导致的错误: 网络错误或HTTP 5xx
+func unreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	return true
+}
+
+// request 按重试策略发送请求, form 为nil时不带表单, result 为nil时不解析响应
+func (i *YingHua) request(method, path string, form map[string]string, result interface{}) (*resty.Response, error) {
+	for attempt := 1; ; attempt++ {
+		// 服务器熔断期间暂停, 恢复后继续
+		if err := i.breaker.Wait(i.Context); err != nil {
+			return nil, err
+		}
+		req := i.client.R()
+		if form != nil {
+			req.SetFormData(form)
+		}
+		if result != nil {
+			req.SetResult(result)
+		}
+		resp, err := req.Execute(method, path)
+		err = check(resp, err)
+		if err != nil && i.stopped() != nil {
+			return resp, i.stopped()
+		}
+		if unreachable(err) {
+			i.breaker.Failure(err)
+		} else {
+			i.breaker.Success()
+		}
+		if err == nil {
+			return resp, nil
+		}
+		if unreachable(err) && i.breaker.Open() {
+			// 熔断期间服务器无法访问的失败不计入重试次数, 平台返回的错误照常处理
+			i.OutputWith(fmt.Sprintf("%s 请求失败: %s, 服务器无法访问, 等待恢复", path, err.Error()), logrus.Warnf)
+			attempt = 0
+			continue
+		}
+		if !i.Retry.Retryable(err) || attempt >= i.Retry.MaxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("%w (已重试 %d 次)", err, attempt-1)
+			}
+			return resp, err
+		}
+		wait := i.Retry.Backoff(attempt)
+		atomic.AddInt64(&i.retries, 1)
+		i.OutputWith(fmt.Sprintf("%s 请求失败: %s, %s 后第 %d 次重试", path, err.Error(), wait.Round(time.Millisecond), attempt), logrus.Warnf)
+		if stopped := i.sleep(wait); stopped != nil {
+			return resp, stopped
+		}
+	}
+}
+
+// post 按重试策略发送POST请求
+func (i *YingHua) post(path string, form map[string]string, result interface{}) (*resty.Response, error) {
+	return i.request(resty.MethodPost, path, form, result)
+}
+
+// Retries 累计重试次数
+func (i *YingHua) Retries() int64 {
+	return atomic.LoadInt64(&i.retries)
+}
@@ -0,0 +1,226 @@
+package yinghua
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/aoaostar/mooc/pkg/config"
+)
+
+// timeoutError 模拟请求超时
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// step 模拟的一次响应, err 不为nil时返回网络错误
+type step struct {
+	status int
+	body   string
+	err    error
+}
+
+// stubTransport 依次返回模拟的响应, 用完后重复最后一个
+type stubTransport struct {
+	steps    []step
+	attempts int32
+	// before 每次请求前调用
+	before func()
+}
+
+func (s *stubTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	attempt := int(atomic.AddInt32(&s.attempts, 1))
+	if s.before != nil {
+		s.before()
+	}
+	current := s.steps[len(s.steps)-1]
+	if attempt <= len(s.steps) {
+		current = s.steps[attempt-1]
+	}
+	if current.err != nil {
+		return nil, current.err
+	}
+	body := current.body
+	if body == "" {
+		body = `{"_code":0,"msg":"ok"}`
+	}
+	return &http.Response{
+		StatusCode: current.status,
+		Status:     http.StatusText(current.status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    request,
+	}, nil
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.Settings
+		attempts int
+		base     time.Duration
+		max      time.Duration
+	}{
+		{name: "默认设置", settings: config.DefaultSettings(), attempts: 4, base: time.Second, max: 30 * time.Second},
+		{name: "重试5次", settings: config.DefaultSettings().Merge(config.Settings{RetryCount: 5, RetryWait: 2, RetryMaxWait: 10}),
+			attempts: 6, base: 2 * time.Second, max: 10 * time.Second},
+		{name: "不重试", settings: config.DefaultSettings().Merge(config.Settings{RetryCount: -1}), attempts: 1, base: time.Second, max: 30 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := NewRetryPolicy(test.settings)
+			if policy.MaxAttempts != test.settings.Retries()+1 || policy.MaxAttempts != test.attempts {
+				t.Fatalf("最多请求次数为 %d, 应为 %d", policy.MaxAttempts, test.attempts)
+			}
+			if policy.BaseWait != test.base || policy.MaxWait != test.max {
+				t.Fatalf("等待时间为 %s/%s, 应为 %s/%s", policy.BaseWait, policy.MaxWait, test.base, test.max)
+			}
+		})
+	}
+
+	policy := NewRetryPolicy(config.DefaultSettings().Merge(config.Settings{RetryCodes: []int{5}}))
+	if !policy.Retryable(&APIError{Code: 5}) || policy.Retryable(&APIError{Code: 7}) {
+		t.Fatal("只应重试 retry_codes 中的 _code")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		random  float64
+		min     time.Duration
+		max     time.Duration
+	}{
+		{name: "第一次最短", attempt: 1, random: 0, min: 500 * time.Millisecond, max: 500 * time.Millisecond},
+		{name: "第一次最长", attempt: 1, random: 0.999, min: 500 * time.Millisecond, max: time.Second - time.Nanosecond},
+		{name: "attempt小于1按1计算", attempt: 0, random: 0, min: 500 * time.Millisecond, max: 500 * time.Millisecond},
+		{name: "第三次翻倍", attempt: 3, random: 0, min: 2 * time.Second, max: 2 * time.Second},
+		{name: "第三次最长", attempt: 3, random: 0.999, min: 2 * time.Second, max: 4*time.Second - time.Nanosecond},
+		{name: "不超过上限", attempt: 10, random: 0.999, min: 5 * time.Second, max: 10*time.Second - time.Nanosecond},
+		{name: "上限的一半", attempt: 10, random: 0, min: 5 * time.Second, max: 5 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			random := test.random
+			policy := RetryPolicy{BaseWait: time.Second, MaxWait: 10 * time.Second, Rand: func() float64 { return random }}
+			if wait := policy.Backoff(test.attempt); wait < test.min || wait > test.max {
+				t.Fatalf("等待时间为 %s, 应在 [%s, %s] 之间", wait, test.min, test.max)
+			}
+		})
+	}
+}
+
+func TestRequestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []step
+		codes    []int
+		attempts int32
+		retries  int64
+		ok       bool
+		status   int
+	}{
+		{name: "5xx后成功", steps: []step{{status: 502}, {status: 500}, {status: 200}}, attempts: 3, retries: 2, ok: true},
+		{name: "超时后成功", steps: []step{{err: timeoutError{}}, {status: 200}}, attempts: 2, retries: 1, ok: true},
+		{name: "429后成功", steps: []step{{status: 429}, {status: 200}}, attempts: 2, retries: 1, ok: true},
+		{name: "4xx不重试", steps: []step{{status: 404}, {status: 200}}, attempts: 1, retries: 0, status: 404},
+		{name: "达到最多请求次数", steps: []step{{status: 503}}, attempts: 3, retries: 2, status: 503},
+		{name: "可以重试的_code", steps: []step{{status: 200, body: `{"_code":5,"msg":"繁忙"}`}, {status: 200}},
+			codes: []int{5}, attempts: 2, retries: 1, ok: true},
+		{name: "其他_code不重试", steps: []step{{status: 200, body: `{"_code":7,"msg":"错误"}`}, {status: 200}},
+			codes: []int{5}, attempts: 1, retries: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := newTestInstance(t, http.NotFoundHandler())
+			transport := &stubTransport{steps: test.steps}
+			instance.client.SetTransport(transport)
+			instance.Retry = RetryPolicy{MaxAttempts: 3, BaseWait: time.Millisecond, MaxWait: 2 * time.Millisecond, RetryCodes: map[int]bool{}}
+			for _, code := range test.codes {
+				instance.Retry.RetryCodes[code] = true
+			}
+
+			_, err := instance.post("/api/course.json", nil, nil)
+			if (err == nil) != test.ok {
+				t.Fatalf("请求结果为 %v, 应为成功=%v", err, test.ok)
+			}
+			if attempts := atomic.LoadInt32(&transport.attempts); attempts != test.attempts {
+				t.Fatalf("请求了 %d 次, 应为 %d 次", attempts, test.attempts)
+			}
+			if retries := instance.Retries(); retries != test.retries {
+				t.Fatalf("累计重试 %d 次, 应为 %d 次", retries, test.retries)
+			}
+			var httpErr *HTTPError
+			if test.status != 0 && (!errors.As(err, &httpErr) || httpErr.StatusCode != test.status) {
+				t.Fatalf("应返回 HTTP %d 错误, 得到 %v", test.status, err)
+			}
+		})
+	}
+}
+
+func TestRequestCancelDuringBackoff(t *testing.T) {
+	instance := newTestInstance(t, http.NotFoundHandler())
+	transport := &stubTransport{steps: []step{{status: 503}}}
+	instance.client.SetTransport(transport)
+	instance.Retry = RetryPolicy{MaxAttempts: 5, BaseWait: time.Minute, MaxWait: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance.Context = ctx
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := instance.post("/api/course.json", nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("等待重试时取消应返回 context.Canceled, 得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("取消后没有及时返回, 用时 %s", elapsed)
+	}
+	if attempts := atomic.LoadInt32(&transport.attempts); attempts != 1 {
+		t.Fatalf("取消前应只请求1次, 实际 %d 次", attempts)
+	}
+}
+
+func TestRequestFatalErrorWhileBreakerOpen(t *testing.T) {
+	instance := newTestInstance(t, http.NotFoundHandler())
+	// 其他账号在请求期间让服务器熔断, 探测一直失败; 熔断器按域名共用, 每次使用新的域名
+	host := "breaker-open-" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".test"
+	instance.breaker = breaker.For(host, 1, time.Hour, func() error { return errors.New("无法访问") })
+	transport := &stubTransport{steps: []step{{status: 200, body: `{"_code":7,"msg":"密码错误"}`}}}
+	transport.before = func() {
+		instance.breaker.Failure(errors.New("无法访问"))
+	}
+	instance.client.SetTransport(transport)
+	instance.Retry = RetryPolicy{MaxAttempts: 3, BaseWait: time.Millisecond, MaxWait: 2 * time.Millisecond, RetryCodes: map[int]bool{}}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := instance.post("/api/login.json", nil, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != 7 {
+			t.Fatalf("应直接返回平台错误, 得到 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("熔断期间不可重试的错误应立即返回, 不应等待恢复")
+	}
+	if !instance.breaker.Open() {
+		t.Fatal("熔断器应处于熔断状态")
+	}
+	if attempts := atomic.LoadInt32(&transport.attempts); attempts != 1 {
+		t.Fatalf("应只请求1次, 实际 %d 次", attempts)
+	}
+}
@@ -0,0 +1,146 @@
+package yinghua
+
+import (
+	"errors"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/util"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// SessionTTL 会话有效期, 超过后重新登录
+const SessionTTL = 6 * time.Hour
+
+// Session 登录会话
+type Session struct {
+	Token   string         `json:"token"`
+	Cookies []*http.Cookie `json:"cookies"`
+	SavedAt time.Time      `json:"saved_at"`
+}
+
+var unsafeChars = regexp.MustCompile(`[^\w.@-]+`)
+
+// SessionPath 会话文件路径
+func SessionPath(user config.User) string {
+	return filepath.Join(config.DataDir, "sessions", unsafeChars.ReplaceAllString(user.Key(), "_")+".json")
+}
+
+// saveSession 保存会话, 文件中有登录凭据, 只允许当前用户读取, 也不保留备份
+func (i *YingHua) saveSession(cookies []*http.Cookie) error {
+	path := SessionPath(i.User)
+	token, _ := i.credentials()
+	err := util.SaveJsonWith(path, Session{
+		Token:   token,
+		Cookies: cookies,
+		SavedAt: time.Now(),
+	}, 0600, 0)
+	if err != nil {
+		return err
+	}
+	// 删除旧版本留下的备份
+	for n := 1; n <= util.DefaultBackups; n++ {
+		_ = os.Remove(util.BackupName(path, n))
+	}
+	return nil
+}
+
+// Resume 恢复上次保存的会话, 会话失效时重新登录
+func (i *YingHua) Resume() error {
+	err := i.restoreSession()
+	if err == nil {
+		return nil
+	}
+	return i.Login()
+}
+
+func (i *YingHua) restoreSession() error {
+	var session Session
+	err := util.LoadJson(SessionPath(i.User), &session)
+	if err != nil {
+		return err
+	}
+	if session.Token == "" || time.Since(session.SavedAt) > SessionTTL {
+		return errors.New("会话已过期")
+	}
+	i.useSession(session.Token, session.Cookies)
+
+	// 请求课程列表验证会话是否有效
+	err = i.GetCourses()
+	if err != nil {
+		i.useSession("", nil)
+		return err
+	}
+	accountOf(i.User).set(session.Token, session.Cookies)
+	return nil
+}
+
+// useSession 之后的请求使用该登录凭据
+func (i *YingHua) useSession(token string, cookies []*http.Cookie) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.token, i.cookies = token, cookies
+}
+
+// credentials 当前使用的登录凭据
+func (i *YingHua) credentials() (string, []*http.Cookie) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.token, i.cookies
+}
+
+// account 账号最近一次登录的凭据, 同一账号的多门课程共用
+type account struct {
+	// login 同一账号同时只有一个协程重新登录
+	login   sync.Mutex
+	mu      sync.Mutex
+	token   string
+	cookies []*http.Cookie
+}
+
+// accounts 全部账号的登录凭据, key为账号标识
+var accounts = struct {
+	sync.Mutex
+	data map[string]*account
+}{data: map[string]*account{}}
+
+func accountOf(user config.User) *account {
+	accounts.Lock()
+	defer accounts.Unlock()
+	a, ok := accounts.data[user.Key()]
+	if !ok {
+		a = &account{}
+		accounts.data[user.Key()] = a
+	}
+	return a
+}
+
+func (a *account) set(token string, cookies []*http.Cookie) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token, a.cookies = token, cookies
+}
+
+func (a *account) get() (string, []*http.Cookie) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token, a.cookies
+}
+
+// relogin 当前会话失效后重新登录, 同一账号同时只有一个协程重新登录
+// 其他课程已经用新的会话替换了失效的会话时直接使用新的会话, 不再登录, 以免让其他课程的会话失效
+func (i *YingHua) relogin() error {
+	a := accountOf(i.User)
+	a.login.Lock()
+	defer a.login.Unlock()
+	token, cookies := a.get()
+	if current, _ := i.credentials(); token != "" && token != current {
+		i.Output("其他课程已经重新登录, 使用新的会话")
+		i.useSession(token, cookies)
+		return nil
+	}
+	return i.Login()
+}
@@ -0,0 +1,310 @@
+package yinghua
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// 节点学习状态
+const (
+	NodeStarting        = "开始学习"
+	NodeHeartbeating    = "学习中"
+	NodeAwaitingCaptcha = "等待验证码"
+	NodeVerifying       = "提交验证码"
+	NodeRecovering      = "卡住恢复"
+	NodeDone            = "已完成"
+	NodeFailed          = "失败"
+)
+
+// heartbeatInterval 提交学习记录的间隔, 每次提交的学习时长随之增加
+const heartbeatInterval = 10 * time.Second
+
+// maxCaptchaAttempts 连续识别验证码失败的次数上限
+const maxCaptchaAttempts = 5
+
+// maxRecoveries 同一节点进度卡住后最多恢复的次数, 超过后放弃该节点
+const maxRecoveries = 3
+
+// timelineSize 保留的进度记录条数
+const timelineSize = 20
+
+// progressPoint 某次查询到的进度
+type progressPoint struct {
+	Time     time.Time
+	Progress float64
+}
+
+func (p progressPoint) String() string {
+	return fmt.Sprintf("%.f%%@%s", p.Progress*100, p.Time.Format("15:04:05"))
+}
+
+// pollResult 查询进度的结果
+type pollResult struct {
+	data types.NodeVideoData
+	err  error
+}
+
+// nodeStudy 单个节点的学习过程
+// 全部状态只由 run 所在的协程读写, 查询进度的协程只通过 updates 传递结果
+type nodeStudy struct {
+	i         *YingHua
+	node      types.ChaptersNodeList
+	state     string
+	studyID   int
+	studyTime int
+	// code 待提交的验证码
+	code     string
+	captchas int
+	progress types.NodeVideoData
+	// timeline 最近的进度记录
+	timeline []progressPoint
+	// best 本次学习记录中的最高进度, gainAt 为进度最后一次增长的时间
+	best   float64
+	gainAt time.Time
+	// flat 进度连续没有增长的查询次数
+	flat       int
+	recoveries int
+	err        error
+	updates    chan pollResult
+	stop       chan struct{}
+}
+
+// StudyNode 学习视频节点直到完成, 查询进度失败、提交失败或学习被中断时返回错误
+func (i *YingHua) StudyNode(node types.ChaptersNodeList) error {
+	i.Output(fmt.Sprintf("当前第 %d 课, [%s][nodeId=%d]", node.Idx, node.Name, node.ID))
+	s := &nodeStudy{
+		i:         i,
+		node:      node,
+		state:     NodeStarting,
+		studyTime: 1,
+		progress: types.NodeVideoData{
+			StudyTotal: types.NodeVideoStudyTotal{Progress: "0.00"},
+		},
+		gainAt:  time.Now(),
+		updates: make(chan pollResult),
+		stop:    make(chan struct{}),
+	}
+	return s.run()
+}
+
+func (s *nodeStudy) run() error {
+	if s.node.VideoState == 2 {
+		return nil
+	}
+	go s.poll()
+	defer close(s.stop)
+	for {
+		var next string
+		switch s.state {
+		case NodeStarting, NodeHeartbeating:
+			next = s.heartbeat()
+		case NodeAwaitingCaptcha:
+			next = s.captcha()
+		case NodeVerifying:
+			next = s.verify()
+		case NodeRecovering:
+			next = s.recover()
+		case NodeDone:
+			return nil
+		case NodeFailed:
+			return s.err
+		}
+		if next != s.state {
+			logrus.Debugf("[%s] %s[nodeId=%d] %s -> %s", s.i.User.Username, s.node.Name, s.node.ID, s.state, next)
+		}
+		s.state = next
+	}
+}
+
+// poll 定时查询学习进度, 查询失败或节点完成后退出
+func (s *nodeStudy) poll() {
+	interval := time.Duration(s.i.Settings.PollInterval) * time.Second
+	for {
+		data, err := s.i.GetNodeProgress(s.node)
+		select {
+		case s.updates <- pollResult{data: data, err: err}:
+		case <-s.stop:
+			return
+		}
+		if err != nil || data.StudyTotal.State == "2" {
+			return
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// fail 进入失败状态
+func (s *nodeStudy) fail(err error) string {
+	s.err = err
+	return NodeFailed
+}
+
+// heartbeat 提交学习记录, 然后等待下一次提交
+func (s *nodeStudy) heartbeat() string {
+	if err := s.i.stopped(); err != nil {
+		return s.fail(err)
+	}
+	resp, err := s.submit()
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && resp.NeedCode {
+			return NodeAwaitingCaptcha
+		}
+		return s.fail(err)
+	}
+	s.report(resp)
+	return s.wait()
+}
+
+// captcha 识别验证码
+func (s *nodeStudy) captcha() string {
+	s.captchas++
+	if s.captchas > maxCaptchaAttempts {
+		return s.fail(fmt.Errorf("连续 %d 次验证码错误", maxCaptchaAttempts))
+	}
+	code, err := s.i.SolveCaptcha()
+	if err != nil {
+		s.i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s", s.node.Name, s.node.ID, err.Error()), logrus.Errorf)
+		return s.fail(err)
+	}
+	s.code = code
+	return NodeVerifying
+}
+
+// verify 带上验证码重新提交学习记录
+func (s *nodeStudy) verify() string {
+	resp, err := s.submit()
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && resp.NeedCode {
+			return NodeAwaitingCaptcha
+		}
+		return s.fail(err)
+	}
+	s.code = ""
+	s.captchas = 0
+	s.report(resp)
+	return s.wait()
+}
+
+// submit 提交学习记录
+func (s *nodeStudy) submit() (*types.StudyNodeResponse, error) {
+	formData := map[string]string{
+		"nodeId":    strconv.Itoa(s.node.ID),
+		"studyTime": strconv.Itoa(s.studyTime),
+		"studyId":   strconv.Itoa(s.studyID),
+	}
+	if s.code != "" {
+		formData["code"] = s.code + "_"
+	}
+	resp := new(types.StudyNodeResponse)
+	_, err := s.i.post("/api/node/study.json", formData, resp)
+	if err != nil {
+		s.i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d][studyTime=%d]", s.node.Name, s.node.ID, err.Error(), s.studyID, s.studyTime), logrus.Errorf)
+		return resp, err
+	}
+	s.studyID = resp.Result.Data.StudyID
+	s.studyTime += int(heartbeatInterval / time.Second)
+	return resp, nil
+}
+
+// report 输出提交结果和当前进度
+func (s *nodeStudy) report(resp *types.StudyNodeResponse) {
+	progress, err := strconv.ParseFloat(s.progress.StudyTotal.Progress, 64)
+	if err != nil {
+		s.i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d]", s.node.Name, s.node.ID, err.Error(), s.studyID), logrus.Errorf)
+		return
+	}
+	s.i.Output(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d], 当前进度: %.f%%", s.node.Name, s.node.ID, resp.Msg, s.studyID, progress*100))
+}
+
+// wait 等待下一次提交, 期间处理查询到的进度
+func (s *nodeStudy) wait() string {
+	timer := time.NewTimer(heartbeatInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return NodeHeartbeating
+		case result := <-s.updates:
+			if result.err != nil {
+				// 查询进度已经按重试策略重试过, 仍然失败时结束当前节点
+				s.i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d]", s.node.Name, s.node.ID, result.err.Error(), s.studyID), logrus.Errorf)
+				return s.fail(result.err)
+			}
+			if result.data.StudyTotal.Progress == "" {
+				result.data.StudyTotal.Progress = "0.00"
+			}
+			s.progress = result.data
+			if s.i.OnProgress != nil {
+				s.i.OnProgress(s.node, s.progress)
+			}
+			if s.progress.StudyTotal.State == "2" {
+				return NodeDone
+			}
+			if s.track(time.Now()) {
+				return NodeRecovering
+			}
+		case <-s.i.done():
+			return s.fail(s.i.stopped())
+		}
+	}
+}
+
+// track 记录查询到的进度, 返回进度是否卡住
+// 连续 stall_polls 次查询或 stall_minutes 分钟进度没有增长视为卡住
+func (s *nodeStudy) track(now time.Time) bool {
+	progress, _ := strconv.ParseFloat(s.progress.StudyTotal.Progress, 64)
+	s.timeline = append(s.timeline, progressPoint{Time: now, Progress: progress})
+	if len(s.timeline) > timelineSize {
+		s.timeline = s.timeline[len(s.timeline)-timelineSize:]
+	}
+	if progress > s.best {
+		s.best = progress
+		s.gainAt = now
+		s.flat = 0
+		return false
+	}
+	s.flat++
+	settings := s.i.Settings
+	return (settings.StallPolls > 0 && s.flat >= settings.StallPolls) ||
+		(settings.StallMinutes > 0 && now.Sub(s.gainAt) >= time.Duration(settings.StallMinutes)*time.Minute)
+}
+
+// recover 进度卡住后按 stall_action 处理
+func (s *nodeStudy) recover() string {
+	s.recoveries++
+	name := fmt.Sprintf("%s[nodeId=%d]", s.node.Name, s.node.ID)
+	s.i.OutputWith(fmt.Sprintf("%s 进度 %.f%% 已经 %s 没有增长(查询 %d 次), 最近进度: %v", name, s.best*100,
+		time.Since(s.gainAt).Round(time.Second), s.flat, s.timeline), logrus.Warnf)
+
+	action := s.i.Settings.StallAction
+	if action == config.StallFail || s.recoveries > maxRecoveries {
+		s.i.OutputWith(fmt.Sprintf("%s 进度卡住, 放弃当前节点", name), logrus.Errorf)
+		return s.fail(fmt.Errorf("学习进度卡在 %.f%%", s.best*100))
+	}
+	if action == config.StallRelogin {
+		s.i.OutputWith(fmt.Sprintf("%s 进度卡住, 重新登录(第 %d 次)", name, s.recoveries), logrus.Warnf)
+		if err := s.i.relogin(); err != nil {
+			s.i.OutputWith(fmt.Sprintf("%s 重新登录失败: %s", name, err.Error()), logrus.Errorf)
+			return s.fail(err)
+		}
+	}
+	s.i.OutputWith(fmt.Sprintf("%s 进度卡住, 重新开始学习记录(第 %d 次)", name, s.recoveries), logrus.Warnf)
+	s.studyID = 0
+	s.studyTime = 1
+	s.flat = 0
+	s.gainAt = time.Now()
+	return NodeStarting
+}
@@ -0,0 +1,372 @@
+package yinghua
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoaostar/mooc/pkg/captcha"
+	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/yinghua/types"
+)
+
+// fakeStudy 模拟学习平台的学习接口
+type fakeStudy struct {
+	sync.Mutex
+	// complete 查询多少次进度后节点完成, 为0时进度一直不增长
+	complete int
+	// code 需要提交的验证码, 为空时不需要验证码
+	code string
+	// delay 每次查询进度的耗时
+	delay    time.Duration
+	chapters []types.ChaptersList
+	polls    map[int]int
+	studies  map[int]int
+	inflight map[int]int
+	// maxInflight 同一节点同时进行的进度查询数的最大值
+	maxInflight int
+	// token 当前有效的登录凭据, 为空时不检查; 每次登录生成新的凭据, 旧的凭据失效
+	token  string
+	logins int
+}
+
+func newFakeStudy() *fakeStudy {
+	return &fakeStudy{polls: map[int]int{}, studies: map[int]int{}, inflight: map[int]int{}}
+}
+
+func (f *fakeStudy) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	_ = request.ParseForm()
+	body := map[string]interface{}{"_code": 0, "msg": "提交成功"}
+	switch request.URL.Path {
+	case "/api/course/chapter.json":
+		f.Lock()
+		body["result"] = map[string]interface{}{"list": f.chapters}
+		f.Unlock()
+	case "/api/node/study.json":
+		id, _ := strconv.Atoi(request.FormValue("nodeId"))
+		f.Lock()
+		if f.code != "" && request.FormValue("code") != f.code+"_" {
+			body = map[string]interface{}{"_code": 9, "msg": "请输入验证码", "need_code": true}
+		} else {
+			f.studies[id]++
+			body["result"] = map[string]interface{}{"data": map[string]interface{}{"studyId": 100 + id}}
+		}
+		f.Unlock()
+	case "/api/login.json":
+		f.Lock()
+		f.logins++
+		f.token = "token-" + strconv.Itoa(f.logins)
+		body["result"] = map[string]interface{}{"data": map[string]interface{}{"token": f.token}}
+		f.Unlock()
+	case "/api/node/video.json":
+		f.Lock()
+		expired := f.token != "" && request.FormValue("token") != f.token
+		f.Unlock()
+		if expired {
+			// 会话失效后进度不再增长
+			body["result"] = map[string]interface{}{"data": map[string]interface{}{"videoDuration": 60,
+				"study_total": map[string]interface{}{"progress": "0.10", "state": "1"}}}
+			break
+		}
+		body["result"] = map[string]interface{}{"data": f.progress(request.FormValue("nodeId"))}
+	case "/service/code/aa":
+		_, _ = writer.Write([]byte("image"))
+		return
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(body)
+}
+
+// progress 记录一次进度查询并返回当前进度
+func (f *fakeStudy) progress(nodeID string) map[string]interface{} {
+	id, _ := strconv.Atoi(nodeID)
+	f.Lock()
+	f.inflight[id]++
+	if f.inflight[id] > f.maxInflight {
+		f.maxInflight = f.inflight[id]
+	}
+	f.polls[id]++
+	polls, complete, delay := f.polls[id], f.complete, f.delay
+	f.Unlock()
+
+	time.Sleep(delay)
+
+	f.Lock()
+	f.inflight[id]--
+	f.Unlock()
+	state, progress := "1", 0.1
+	if complete > 0 {
+		progress = float64(polls) / float64(complete)
+		if polls >= complete {
+			state, progress = "2", 1
+		}
+	}
+	return map[string]interface{}{"videoDuration": 60,
+		"study_total": map[string]interface{}{"progress": strconv.FormatFloat(progress, 'f', 2, 64), "state": state}}
+}
+
+// pollCount 节点被查询进度的次数
+func (f *fakeStudy) pollCount(id int) int {
+	f.Lock()
+	defer f.Unlock()
+	return f.polls[id]
+}
+
+// stubSolver 直接返回固定的验证码
+type stubSolver struct {
+	code  string
+	calls int32
+}
+
+func (s *stubSolver) Solve(ctx context.Context, request captcha.Request) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return s.code, nil
+}
+
+// newTestInstance 使用模拟平台创建账号实例, 不等待查询进度、不重试
+func newTestInstance(t *testing.T, handler http.Handler) *YingHua {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Conf
+	t.Cleanup(func() {
+		config.Conf = old
+		_ = os.Chdir(wd)
+	})
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{BreakerThreshold: -1}}}
+	instance := New(config.User{Username: "tester", Password: "pw", BaseURL: server.URL})
+	instance.Settings.PollInterval = 0
+	instance.Retry = RetryPolicy{MaxAttempts: 1}
+	return instance
+}
+
+func videoNode(id int) types.ChaptersNodeList {
+	return types.ChaptersNodeList{ID: id, Name: "第" + strconv.Itoa(id) + "节", TabVideo: true, VideoDuration: "60"}
+}
+
+// assertPollerStopped 节点学习结束后不应再查询进度
+// 先等待结束时可能正在进行的一次查询, 查询间隔为0, 协程没有退出时进度会被一直查询
+func assertPollerStopped(t *testing.T, platform *fakeStudy, id int) {
+	t.Helper()
+	time.Sleep(20 * time.Millisecond)
+	polls := platform.pollCount(id)
+	time.Sleep(50 * time.Millisecond)
+	if after := platform.pollCount(id); after != polls {
+		t.Fatalf("节点学习结束后仍在查询进度: %d -> %d", polls, after)
+	}
+}
+
+func TestStudyNodePollsUntilDone(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 3
+	instance := newTestInstance(t, platform)
+	var progress []string
+	instance.OnProgress = func(node types.ChaptersNodeList, data types.NodeVideoData) {
+		progress = append(progress, data.StudyTotal.Progress)
+	}
+
+	if err := instance.StudyNode(videoNode(1)); err != nil {
+		t.Fatalf("学习节点失败: %v", err)
+	}
+	want := []string{"0.33", "0.67", "1.00"}
+	if len(progress) != len(want) {
+		t.Fatalf("进度回调为 %v, 应为 %v", progress, want)
+	}
+	for index := range want {
+		if progress[index] != want[index] {
+			t.Fatalf("进度回调为 %v, 应为 %v", progress, want)
+		}
+	}
+	if platform.pollCount(1) != 3 {
+		t.Fatalf("应查询进度3次, 实际 %d 次", platform.pollCount(1))
+	}
+	assertPollerStopped(t, platform, 1)
+}
+
+func TestStudyNodeSinglePoller(t *testing.T) {
+	platform := newFakeStudy()
+	platform.delay = 2 * time.Millisecond
+	instance := newTestInstance(t, platform)
+	// 进度一直不增长, 多次重新开始学习记录后放弃
+	instance.Settings.StallPolls = 2
+	instance.Settings.StallMinutes = -1
+	instance.Settings.StallAction = config.StallRestart
+
+	err := instance.StudyNode(videoNode(2))
+	if err == nil {
+		t.Fatal("进度卡住时应返回错误")
+	}
+	platform.Lock()
+	studies, maxInflight := platform.studies[2], platform.maxInflight
+	platform.Unlock()
+	if studies != maxRecoveries+1 {
+		t.Fatalf("应提交学习记录 %d 次, 实际 %d 次", maxRecoveries+1, studies)
+	}
+	if maxInflight != 1 {
+		t.Fatalf("同一节点同时只应有一个查询进度的协程, 实际最多 %d 个", maxInflight)
+	}
+	assertPollerStopped(t, platform, 2)
+}
+
+func TestStudyNodeCaptcha(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 2
+	platform.code = "abcd"
+	instance := newTestInstance(t, platform)
+	solver := &stubSolver{code: "abcd"}
+	instance.Captcha = solver
+
+	if err := instance.StudyNode(videoNode(3)); err != nil {
+		t.Fatalf("输入验证码后应继续学习: %v", err)
+	}
+	if calls := atomic.LoadInt32(&solver.calls); calls != 1 {
+		t.Fatalf("应识别验证码1次, 实际 %d 次", calls)
+	}
+	platform.Lock()
+	studies := platform.studies[3]
+	platform.Unlock()
+	if studies != 1 {
+		t.Fatalf("带验证码的学习记录应提交成功1次, 实际 %d 次", studies)
+	}
+}
+
+func TestStudyNodeCancel(t *testing.T) {
+	platform := newFakeStudy()
+	instance := newTestInstance(t, platform)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instance.Context = ctx
+	instance.OnProgress = func(node types.ChaptersNodeList, data types.NodeVideoData) {
+		cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- instance.StudyNode(videoNode(4))
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("取消后应返回 context.Canceled, 得到 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后没有及时停止学习")
+	}
+	assertPollerStopped(t, platform, 4)
+}
+
+func TestStudyCourseSkipsLockedNode(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 1
+	locked := videoNode(10)
+	locked.NodeLock = 1
+	platform.chapters = []types.ChaptersList{{ID: 1, Name: "第一章", Idx: 1, NodeList: []types.ChaptersNodeList{locked, videoNode(11)}}}
+	instance := newTestInstance(t, platform)
+	var skips []NodeSkip
+	instance.OnSkip = func(skip NodeSkip) {
+		skips = append(skips, skip)
+	}
+	var done []int
+	instance.NodeDone = func(node types.ChaptersNodeList) {
+		done = append(done, node.ID)
+	}
+
+	if err := instance.StudyCourse(types.CoursesList{ID: 1}); err != nil {
+		t.Fatalf("未解锁的节点不应导致课程学习失败: %v", err)
+	}
+	if len(skips) != 1 || skips[0].Node.ID != 10 || skips[0].Reason != SkipLocked {
+		t.Fatalf("应跳过未解锁的节点一次, 实际 %+v", skips)
+	}
+	if len(done) != 1 || done[0] != 11 {
+		t.Fatalf("应学完后面已解锁的节点, 实际 %v", done)
+	}
+	platform.Lock()
+	defer platform.Unlock()
+	if platform.studies[10] != 0 || platform.polls[10] != 0 {
+		t.Fatal("不应学习未解锁的节点")
+	}
+}
+
+func TestStudyCourseParksTimedNode(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 1
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	parked := videoNode(20)
+	parked.UnlockTimeStamp = int(until.Unix())
+	platform.chapters = []types.ChaptersList{{ID: 2, Name: "第二章", Idx: 1, NodeList: []types.ChaptersNodeList{parked, videoNode(21)}}}
+	instance := newTestInstance(t, platform)
+	var skips []NodeSkip
+	instance.OnSkip = func(skip NodeSkip) {
+		skips = append(skips, skip)
+	}
+
+	err := instance.StudyCourse(types.CoursesList{ID: 2})
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("有节点没到解锁时间时应返回 *LockedError, 得到 %v", err)
+	}
+	if !locked.Until.Equal(until) || locked.Nodes != 1 {
+		t.Fatalf("解锁时间或节点数不正确: %+v", locked)
+	}
+	if len(skips) != 1 || !skips[0].Until.Equal(until) {
+		t.Fatalf("跳过记录应带上解锁时间, 实际 %+v", skips)
+	}
+	platform.Lock()
+	defer platform.Unlock()
+	if platform.studies[20] != 0 || platform.studies[21] != 1 {
+		t.Fatalf("应只学习已解锁的节点, 实际 %v", platform.studies)
+	}
+}
+
+func TestStudyNodeReloginOncePerAccount(t *testing.T) {
+	platform := newFakeStudy()
+	platform.complete = 2
+	platform.token = "expired"
+	first := newTestInstance(t, platform)
+	first.Settings.StallPolls = 2
+	first.Settings.StallMinutes = -1
+	first.Settings.StallAction = config.StallRelogin
+	// 同一账号的另一门课程
+	second := New(first.User)
+	second.Settings = first.Settings
+	second.Retry = first.Retry
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for index, instance := range []*YingHua{first, second} {
+		wg.Add(1)
+		go func(index int, instance *YingHua) {
+			defer wg.Done()
+			errs[index] = instance.StudyNode(videoNode(40 + index))
+		}(index, instance)
+	}
+	wg.Wait()
+	for index, err := range errs {
+		if err != nil {
+			t.Fatalf("第 %d 门课程重新登录后应学完: %v", index+1, err)
+		}
+	}
+	platform.Lock()
+	defer platform.Unlock()
+	if platform.logins != 1 {
+		t.Fatalf("同一账号应只重新登录1次, 实际 %d 次", platform.logins)
+	}
+}
@@ -0,0 +1,85 @@
+package yinghua
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/aoaostar/mooc/pkg/config"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// 相同网络设置的账号共用一个 Transport, 按域名复用连接
+var transports = struct {
+	sync.Mutex
+	data map[string]*http.Transport
+}{data: map[string]*http.Transport{}}
+
+// Transport 按代理、证书和超时设置返回共用的 Transport
+func Transport(settings config.Settings) (*http.Transport, error) {
+	key := fmt.Sprintf("%s|%s|%t|%d|%d", settings.Proxy, settings.CAFile, settings.InsecureSkipVerify,
+		settings.ConnectTimeout, settings.ReadTimeout)
+	transports.Lock()
+	defer transports.Unlock()
+	if transport, ok := transports.data[key]; ok {
+		return transport, nil
+	}
+	transport, err := newTransport(settings)
+	if err != nil {
+		return nil, err
+	}
+	transports.data[key] = transport
+	return transport, nil
+}
+
+func newTransport(settings config.Settings) (*http.Transport, error) {
+	// 没有设置代理时使用 HTTP_PROXY、HTTPS_PROXY 环境变量
+	proxy := http.ProxyFromEnvironment
+	if settings.Proxy != "" {
+		proxyURL, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址 %q 错误: %s", settings.Proxy, err.Error())
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+	if settings.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %s", err.Error())
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA证书 %s 中没有有效的PEM证书", settings.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	connectTimeout := time.Duration(settings.ConnectTimeout) * time.Second
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: time.Duration(settings.ReadTimeout) * time.Second,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package yinghua
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aoaostar/mooc/pkg/captcha"
+	"github.com/aoaostar/mooc/pkg/config"
+)
+
+func TestRemoteCaptchaVerifiesCertificate(t *testing.T) {
+	// 自签名证书的服务器, 同时作为学校平台和识别服务
+	server := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(writer, `{"_code":0,"status":"ok","data":"x7k2"}`)
+	}))
+	defer server.Close()
+	old := config.Conf
+	defer func() {
+		config.Conf = old
+	}()
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{BreakerThreshold: -1}}}
+	instance := New(config.User{Username: "tester", BaseURL: server.URL, Settings: config.Settings{
+		InsecureSkipVerify: true, Captcha: config.CaptchaRemote, CaptchaURL: server.URL,
+	}})
+	instance.Retry = RetryPolicy{MaxAttempts: 1}
+
+	if _, err := instance.post("/api/course.json", nil, nil); err != nil {
+		t.Fatalf("学校平台设置了不校验证书, 请求应成功, 得到 %v", err)
+	}
+	_, err := instance.Captcha.(captcha.Remote).Solve(context.Background(), captcha.Request{Image: []byte("png")})
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("识别服务应校验证书, 得到 %v", err)
+	}
+}
+
+func TestTransportShared(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(writer, `{"_code":0,"msg":"ok"}`)
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Conf
+	defer func() {
+		config.Conf = old
+	}()
+	config.Conf = config.Config{Global: config.Global{Settings: config.Settings{BreakerThreshold: -1}}}
+	newInstance := func(username string, settings config.Settings) *YingHua {
+		instance := New(config.User{Username: username, BaseURL: server.URL, Settings: settings})
+		instance.Retry = RetryPolicy{MaxAttempts: 1}
+		return instance
+	}
+	transportOf := func(instance *YingHua) http.RoundTripper {
+		return instance.client.GetClient().Transport
+	}
+
+	// 同一个学校的两个账号
+	alice := newInstance("alice", config.Settings{CAFile: caFile})
+	bob := newInstance("bob", config.Settings{CAFile: caFile})
+	if transportOf(alice) != transportOf(bob) {
+		t.Fatal("相同网络设置的账号应共用 Transport")
+	}
+	for _, instance := range []*YingHua{alice, bob} {
+		if _, err := instance.post("/api/course.json", nil, nil); err != nil {
+			t.Fatalf("[%s] 设置了学校的CA证书, 请求应成功, 得到 %v", instance.User.Username, err)
+		}
+	}
+
+	// 另一个学校没有设置证书, 不受其他学校设置的影响
+	other := newInstance("carol", config.Settings{})
+	if transportOf(other) == transportOf(alice) {
+		t.Fatal("证书设置不同的学校不应共用 Transport")
+	}
+	_, err := other.post("/api/course.json", nil, nil)
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("没有设置证书的学校应校验证书, 得到 %v", err)
+	}
+	insecure := newInstance("dave", config.Settings{InsecureSkipVerify: true})
+	if transport := transportOf(insecure); transport == transportOf(other) || !transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify {
+		t.Fatal("不校验证书的学校应使用单独的 Transport")
+	}
+	if _, err := insecure.post("/api/course.json", nil, nil); err != nil {
+		t.Fatalf("设置了不校验证书, 请求应成功, 得到 %v", err)
+	}
+
+	for _, settings := range []config.Settings{{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, {Proxy: "://proxy"}} {
+		if _, err := Transport(settings); err == nil {
+			t.Fatalf("错误的网络设置 %+v 应返回错误", settings)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"strconv"
+	"strings"
+)
+
 type ChaptersResponse struct {
 	Code   int            `json:"_code"`
 	Status bool           `json:"status"`
@@ -47,3 +52,42 @@ type StudyNodeData struct {
 type StudyNodeResult struct {
 	Data StudyNodeData `json:"data"`
 }
+
+// VideoSeconds 视频总时长, 秒
+func (n ChaptersNodeList) VideoSeconds() int {
+	return ParseDuration(n.VideoDuration)
+}
+
+// StudiedSeconds 已学习时长, 秒
+func (n ChaptersNodeList) StudiedSeconds() int {
+	return ParseDuration(n.Duration)
+}
+
+// RemainingSeconds 视频剩余学习时长, 秒, 已完成或非视频节点为0
+func (n ChaptersNodeList) RemainingSeconds() int {
+	if !n.TabVideo || n.VideoState == 2 {
+		return 0
+	}
+	remaining := n.VideoSeconds() - n.StudiedSeconds()
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// ParseDuration 解析时长, 支持 "HH:MM:SS"、"MM:SS" 和秒数, 无法解析时返回0
+func ParseDuration(text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+	seconds := 0.0
+	for _, part := range strings.Split(text, ":") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+	return int(seconds)
+}
@@ -43,3 +43,9 @@ type LoginData struct {
 type LoginResult struct {
 	Data LoginData `json:"data"`
 }
+
+// Status 全部接口共有的状态字段
+type Status struct {
+	Code int    `json:"_code"`
+	Msg  string `json:"msg"`
+}
@@ -1,5 +1,7 @@
 package types
 
+import "strconv"
+
 // NodeVideoResponse GetNodeProgress
 type NodeVideoResponse struct {
 	Code   int    `json:"_code"`
@@ -27,3 +29,20 @@ type NodeVideoData struct {
 type Result struct {
 	Data NodeVideoData `json:"data"`
 }
+
+// RemainingSeconds 根据学习进度计算视频剩余学习时长, 秒
+func (d NodeVideoData) RemainingSeconds() int {
+	if d.StudyTotal.State == "2" {
+		return 0
+	}
+	studied := ParseDuration(d.StudyTotal.Duration)
+	if studied == 0 {
+		progress, _ := strconv.ParseFloat(d.StudyTotal.Progress, 64)
+		studied = int(progress * float64(d.VideoDuration))
+	}
+	remaining := d.VideoDuration - studied
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
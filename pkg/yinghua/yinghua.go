@@ -1,42 +1,127 @@
 package yinghua
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	browser "github.com/EDDYCJY/fake-useragent"
+	"github.com/aoaostar/mooc/pkg/breaker"
+	"github.com/aoaostar/mooc/pkg/captcha"
 	"github.com/aoaostar/mooc/pkg/config"
+	"github.com/aoaostar/mooc/pkg/ratelimit"
 	"github.com/aoaostar/mooc/pkg/util"
 	"github.com/aoaostar/mooc/pkg/yinghua/types"
 	"github.com/go-resty/resty/v2"
 	"github.com/sirupsen/logrus"
+	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type YingHua struct {
-	User    config.User
-	Courses []types.CoursesList
-	client  *resty.Client
+	User     config.User
+	Settings config.Settings
+	Courses  []types.CoursesList
+	client   *resty.Client
+	// mu 保护 token 和 cookies, 重新登录时查询进度的协程可能同时在发送请求
+	mu      sync.Mutex
+	token   string
+	cookies []*http.Cookie
+	// OnChapters 获取到课程章节后回调
+	OnChapters func(chapters []types.ChaptersList)
+	// OnProgress 获取到节点学习进度后回调
+	OnProgress func(node types.ChaptersNodeList, progress types.NodeVideoData)
+	// NodeDone 节点学习完成回调
+	NodeDone func(node types.ChaptersNodeList)
+	// SkipNode 返回 true 的节点不再学习, 用于从断点继续
+	SkipNode func(node types.ChaptersNodeList) bool
+	// OnSkip 节点因未解锁或不是视频被跳过时回调
+	OnSkip func(skip NodeSkip)
+	// Context 取消后停止学习, 为nil时不会中断
+	Context context.Context
+	// Captcha 验证码识别方式
+	Captcha captcha.Solver
+	// Retry 请求重试策略
+	Retry   RetryPolicy
+	retries int64
+	breaker *breaker.Breaker
 }
 
 func New(user config.User) *YingHua {
 
+	settings := config.EffectiveSettings(user)
+	userAgent := settings.UserAgent
+	if userAgent == "" {
+		userAgent = browser.Mobile()
+	}
+
 	var client = resty.New()
+	transport, err := Transport(settings)
+	if err != nil {
+		logrus.Errorf("[%s] 网络设置错误, 使用默认设置: %s", user.Username, err.Error())
+	} else {
+		client.SetTransport(transport)
+	}
 	client.SetBaseURL(user.BaseURL)
-	client.SetRetryCount(3)
-	client.SetHeader("user-agent", browser.Mobile())
-	return &YingHua{
-		User:   user,
-		client: client,
+	client.SetTimeout(time.Duration(settings.Timeout) * time.Second)
+	client.SetHeader("user-agent", userAgent)
+	instance := &YingHua{
+		User:     user,
+		Settings: settings,
+		client:   client,
+		Retry:    NewRetryPolicy(settings),
+		Captcha:  captcha.Manual{Timeout: time.Duration(settings.CaptchaTimeout) * time.Second},
+	}
+	if settings.Captcha == config.CaptchaRemote {
+		// 识别服务不是学校的网站, 不使用学校的证书和跳过校验的设置
+		remote := resty.New()
+		remote.SetTimeout(time.Duration(settings.Timeout) * time.Second)
+		instance.Captcha = captcha.Remote{URL: settings.CaptchaURL, Client: remote}
 	}
+	instance.breaker = breaker.For(user.Host(), settings.BreakerThreshold, time.Duration(settings.BreakerInterval)*time.Second,
+		probe(user.BaseURL, transport, time.Duration(settings.Timeout)*time.Second))
+	limiter := ratelimit.For(user.Host(), settings.RateLimit)
+	client.OnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
+		token, cookies := instance.credentials()
+		if token != "" {
+			req.FormData.Set("token", token)
+		}
+		req.SetCookies(cookies)
+		wait, err := limiter.Wait(instance.Context)
+		if wait > 0 {
+			logrus.Debugf("[%s] %s 限速等待 %s", user.Host(), req.URL, wait.Round(time.Millisecond))
+		}
+		return err
+	})
+	return instance
 
 }
 
+// probe 探测服务器是否恢复, 只使用共用的 Transport, 不带任何账号的会话
+func probe(baseURL string, transport *http.Transport, timeout time.Duration) func() error {
+	client := &http.Client{Timeout: timeout}
+	if transport != nil {
+		client.Transport = transport
+	}
+	return func() error {
+		// 只要服务器有响应就认为已经恢复
+		resp, err := client.Head(strings.TrimRight(baseURL, "/") + "/")
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return nil
+	}
+}
+
 func (i *YingHua) Login() error {
 
 	resp := new(types.LoginResponse)
-	resp2, err := i.client.R().SetFormData(map[string]string{
+	resp2, err := i.post("/api/login.json", map[string]string{
 		"platform":  "Android",
 		"username":  i.User.Username,
 		"password":  i.User.Password,
@@ -44,23 +129,19 @@ func (i *YingHua) Login() error {
 		"school_id": strconv.Itoa(i.User.SchoolID),
 		"imgSign":   "533560501d19cc30271a850810b09e3e",
 		"imgCode":   "cryd",
-	}).
-		SetResult(resp).
-		Post("/api/login.json")
+	}, resp)
 
 	if err != nil {
 		return err
 	}
-	if resp.Code != 0 {
-		return errors.New(resp.Msg)
-	}
 
-	i.client.SetCookies(resp2.Cookies())
+	i.useSession(resp.Result.Data.Token, resp2.Cookies())
+	accountOf(i.User).set(resp.Result.Data.Token, resp2.Cookies())
 
-	i.client.OnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
-		req.FormData.Set("token", resp.Result.Data.Token)
-		return nil
-	})
+	err = i.saveSession(resp2.Cookies())
+	if err != nil {
+		i.OutputWith(fmt.Sprintf("保存会话失败: %s", err.Error()), logrus.Warnf)
+	}
 
 	return nil
 
@@ -69,17 +150,11 @@ func (i *YingHua) Login() error {
 func (i *YingHua) GetCourses() error {
 
 	resp := new(types.CoursesResponse)
-	_, err := i.client.R().
-		SetResult(resp).
-		Post("/api/course.json")
+	_, err := i.post("/api/course.json", nil, resp)
 
 	if err != nil {
 		return err
 	}
-
-	if resp.Code != 0 {
-		return errors.New(resp.Msg)
-	}
 	i.Courses = resp.Result.List
 	return nil
 }
@@ -87,166 +162,171 @@ func (i *YingHua) GetCourses() error {
 func (i *YingHua) GetChapters(course types.CoursesList) ([]types.ChaptersList, error) {
 
 	resp := new(types.ChaptersResponse)
-	_, err := i.client.R().
-		SetResult(resp).
-		SetFormData(map[string]string{
-			"courseId": strconv.Itoa(course.ID),
-		}).
-		Post("/api/course/chapter.json")
+	_, err := i.post("/api/course/chapter.json", map[string]string{
+		"courseId": strconv.Itoa(course.ID),
+	}, resp)
 
 	if err != nil {
 		return nil, err
 	}
-
-	if resp.Code != 0 {
-		return nil, errors.New(resp.Msg)
-	}
 	return resp.Result.List, nil
 }
 
+// StudyCourse 学习课程, 有节点没到解锁时间时返回 *LockedError
+// 被前面节点锁住的节点在本轮学完后重新获取章节继续学习
 func (i *YingHua) StudyCourse(course types.CoursesList) error {
-	chapters, err := i.GetChapters(course)
-	if err != nil {
-		return err
+	pass := newStudyPass(Sequential(course))
+	for {
+		chapters, err := i.GetChapters(course)
+		if err != nil {
+			return err
+		}
+		if i.OnChapters != nil {
+			i.OnChapters(chapters)
+		}
+		pass.studied, pass.locked, pass.parked, pass.stop = 0, 0, nil, false
+		for _, chapter := range chapters {
+			err = i.studyChapter(chapter, pass)
+			if err != nil {
+				return err
+			}
+			if pass.stop {
+				break
+			}
+		}
+		if pass.studied == 0 || pass.locked == 0 {
+			break
+		}
+		i.Output(fmt.Sprintf("本轮学完 %d 个节点, 重新获取章节学习后续节点", pass.studied))
 	}
-	for _, chapter := range chapters {
-		i.StudyChapter(chapter)
+	if len(pass.parked) > 0 {
+		until := pass.parked[0].Until
+		for _, skip := range pass.parked {
+			if skip.Until.Before(until) {
+				until = skip.Until
+			}
+		}
+		return &LockedError{Until: until, Nodes: len(pass.parked)}
 	}
-
 	return nil
 }
 
-func (i *YingHua) StudyChapter(chapter types.ChaptersList) {
+// StudyChapter 学习章节中的视频, 只在学习被中断时返回错误
+func (i *YingHua) StudyChapter(chapter types.ChaptersList) error {
+	return i.studyChapter(chapter, newStudyPass(false))
+}
+
+func (i *YingHua) studyChapter(chapter types.ChaptersList, pass *studyPass) error {
 
 	i.Output(fmt.Sprintf("当前第 %d 章, [%s][chapterId=%d]", chapter.Idx, chapter.Name, chapter.ID))
 	for _, node := range chapter.NodeList {
 		// 试题跳过
-		if node.TabVideo {
-			i.StudyNode(node)
-		}
-	}
-
-}
-
-func (i *YingHua) StudyNode(node types.ChaptersNodeList) {
-startStudy:
-	i.Output(fmt.Sprintf("当前第 %d 课, [%s][nodeId=%d]", node.Idx, node.Name, node.ID))
-	var studyTime = 1
-	var studyId = 0
-	var nodeProgress = types.NodeVideoData{
-		StudyTotal: types.NodeVideoStudyTotal{
-			Progress: "0.00",
-		},
-	}
-	var flag = true
-	go func() {
-		for flag {
-			var err error
-			nodeProgress, err = i.GetNodeProgress(node)
-			if err != nil {
-				i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d]", node.Name, node.ID, err.Error(), studyId), logrus.Errorf)
-				flag = false
-				break
-			}
-			if nodeProgress.StudyTotal.State == "2" {
-				node.VideoState = 2
-				break
-			}
-			time.Sleep(time.Second * 10)
-		}
-	}()
-
-	for node.VideoState != 2 {
-		if !flag {
-			goto startStudy
+		if !node.TabVideo {
+			i.skip(pass, NodeSkip{Node: node, Reason: SkipNotVideo})
+			continue
 		}
-
-		var formData = map[string]string{
-			"nodeId":    strconv.Itoa(node.ID),
-			"studyTime": strconv.Itoa(studyTime),
-			"studyId":   strconv.Itoa(studyId),
+		if pass.done[node.ID] || (i.SkipNode != nil && i.SkipNode(node)) {
+			continue
 		}
-	captcha:
-		var resp = new(types.StudyNodeResponse)
-		_, err := i.client.R().
-			SetFormData(formData).
-			SetResult(resp).
-			Post("/api/node/study.json")
-		if err != nil {
-			i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d][studyTime=%d]", node.Name, node.ID, err.Error(), studyId, studyTime), logrus.Errorf)
+		if until := UnlockAt(node, time.Now()); !until.IsZero() {
+			skip := NodeSkip{Node: node, Reason: fmt.Sprintf("%s 解锁", until.Format("01-02 15:04")), Until: until}
+			pass.parked = append(pass.parked, skip)
+			i.skip(pass, skip)
+			if pass.sequential {
+				pass.stop = true
+				return nil
+			}
 			continue
 		}
-		if resp.Code != 0 {
-			i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d][studyTime=%d]", node.Name, node.ID, resp.Msg, studyId, studyTime), logrus.Errorf)
-			if resp.NeedCode {
-				formData["code"] = i.FuckCaptcha() + "_"
-				goto captcha
+		if node.NodeLock != 0 {
+			pass.locked++
+			if pass.sequential {
+				i.skip(pass, NodeSkip{Node: node, Reason: SkipBlocked})
+				pass.stop = true
+				return nil
 			}
-			flag = false
-			break
+			i.skip(pass, NodeSkip{Node: node, Reason: SkipLocked})
+			continue
 		}
-		studyId = resp.Result.Data.StudyID
-		if nodeProgress.StudyTotal.Progress == "" {
-			nodeProgress.StudyTotal.Progress = "0.00"
-
+		err := i.StudyNode(node)
+		if stopped := i.stopped(); stopped != nil {
+			return stopped
 		}
-		parseFloat, err := strconv.ParseFloat(nodeProgress.StudyTotal.Progress, 64)
-
-		if err != nil {
-			i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d]", node.Name, node.ID, err.Error(), studyId), logrus.Errorf)
-			continue
+		if err == nil {
+			pass.studied++
+			pass.done[node.ID] = true
+			if i.NodeDone != nil {
+				i.NodeDone(node)
+			}
 		}
-		i.Output(fmt.Sprintf("%s[nodeId=%d], %s[studyId=%d], 当前进度: %.f%%", node.Name, node.ID, resp.Msg, studyId, parseFloat*100))
-		studyTime += 10
-		time.Sleep(time.Second * 10)
 	}
+	return nil
 }
 
-func (i *YingHua) GetNodeProgress(node types.ChaptersNodeList) (types.NodeVideoData, error) {
+// stopped 学习被中断时返回原因
+func (i *YingHua) stopped() error {
+	if i.Context == nil {
+		return nil
+	}
+	return i.Context.Err()
+}
 
-	var resp = new(types.NodeVideoResponse)
-	_, err := i.client.R().
-		SetFormData(map[string]string{
-			"nodeId": strconv.Itoa(node.ID),
-		}).
-		SetResult(resp).
-		Post("/api/node/video.json")
-	if err != nil {
-		i.OutputWith(fmt.Sprintf("%s[nodeId=%d], %s", node.Name, node.ID, err.Error()), logrus.Errorf)
-		return resp.Result.Data, nil
+// done 学习被中断时关闭, 没有设置 Context 时返回nil
+func (i *YingHua) done() <-chan struct{} {
+	if i.Context == nil {
+		return nil
+	}
+	return i.Context.Done()
+}
+
+// sleep 等待一段时间, 期间学习被中断时立即返回原因
+func (i *YingHua) sleep(duration time.Duration) error {
+	if i.Context == nil {
+		time.Sleep(duration)
+		return nil
 	}
-	if resp.Code != 0 {
-		return resp.Result.Data, errors.New(resp.Msg)
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-i.Context.Done():
+		return i.Context.Err()
 	}
-	return resp.Result.Data, nil
 }
 
-func (i *YingHua) FuckCaptcha() string {
+func (i *YingHua) GetNodeProgress(node types.ChaptersNodeList) (types.NodeVideoData, error) {
 
-	i.Output("正在识别验证码")
-	response, err := i.client.R().
-		Get(fmt.Sprintf("/service/code/aa?t=%d", time.Now().UnixNano()))
+	var resp = new(types.NodeVideoResponse)
+	_, err := i.post("/api/node/video.json", map[string]string{
+		"nodeId": strconv.Itoa(node.ID),
+	}, resp)
+	return resp.Result.Data, err
+}
 
+// SolveCaptcha 获取验证码图片并按设置识别
+func (i *YingHua) SolveCaptcha() (string, error) {
+	response, err := i.request(resty.MethodGet, fmt.Sprintf("/service/code/aa?t=%d", time.Now().UnixNano()), nil, nil)
 	if err != nil {
-		i.OutputWith(err.Error(), logrus.Errorf)
+		return "", fmt.Errorf("获取验证码失败: %w", err)
 	}
-	var resp = new(types.Captcha)
-	client := resty.New()
-	_, err = client.R().
-		SetFileReader("file", "image.png", bytes.NewReader(response.Body())).
-		SetResult(resp).
-		Post("https://api.opop.vip/captcha/recognize")
-
-	if err != nil {
-		i.OutputWith(err.Error(), logrus.Errorf)
+	ctx := i.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	if resp.Status != "ok" {
-		i.OutputWith(resp.Message, logrus.Errorf)
+	if i.Settings.Captcha == config.CaptchaRemote {
+		i.Output("正在识别验证码")
+	} else {
+		i.OutputWith("需要输入验证码, 请在GUI、web端或命令行中输入", logrus.Warnf)
 	}
-	s := resp.Data.(string)
-	i.Output(fmt.Sprintf("验证码识别成功: %s", s))
-	return s
+	code, err := i.Captcha.Solve(ctx, captcha.Request{User: i.User.Key(), Image: response.Body()})
+	if err != nil {
+		return "", fmt.Errorf("验证码识别失败: %w", err)
+	}
+	i.Output(fmt.Sprintf("获取验证码成功: %s", code))
+	return code, nil
 }
+
 func (i *YingHua) Output(message string) {
 	i.OutputWith(message, logrus.Infof)
 }